@@ -0,0 +1,81 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "path/filepath"
+    "time"
+
+    "github.com/mohammadtauchid/golang-cache/v2/analyzer"
+    "github.com/mohammadtauchid/golang-cache/v2/reader"
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// analyze streams a trace and reports its characteristics, both on stdout
+// and in output/analyze/<timestamp>_analyze_<trace>.txt
+func analyze(args []string) {
+    var (
+        flags       *flag.FlagSet = flag.NewFlagSet("analyze", flag.ExitOnError)
+        format      string
+        readOptions reader.Options
+        filePath    string
+        r           reader.Reader
+        a           *analyzer.Analyzer = analyzer.NewAnalyzer()
+        trace       simulator.Trace
+        report      analyzer.Report
+        out         *os.File
+        err         error
+    )
+
+    flags.StringVar(&format, "format", "auto", fmt.Sprintf("trace format, one of %v", reader.Formats))
+    flags.BoolVar(&readOptions.SkipBadLines, "skip-bad-lines", false, "skip trace lines that cannot be parsed")
+    flags.BoolVar(&readOptions.Hex, "hex", false, "trace addresses are hexadecimal")
+    flags.BoolVar(&readOptions.StringKeys, "string-keys", false, "trace addresses are opaque string keys, e.g. URLs")
+    flags.Usage = func() {
+        fmt.Println("Usage: ./main analyze [options] [trace file path]")
+        fmt.Println("Example: ./main analyze resource/Financial")
+        fmt.Println("Options:")
+        flags.PrintDefaults()
+    }
+    flags.Parse(args)
+
+    if flags.NArg() != 1 {
+        flags.Usage()
+        os.Exit(1)
+    }
+    filePath = flags.Arg(0)
+
+    r, err = reader.Open(filePath, format, readOptions)
+    if err != nil {
+        log.Fatalf("Error reading file: %v", err)
+    }
+    defer r.Close()
+
+    for {
+        trace, err = r.Read()
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        if err != nil {
+            log.Fatalf("Error reading file: %v", err)
+        }
+        a.Add(trace)
+    }
+
+    report = a.Report()
+    report.Skipped = reader.Skipped(r)
+    report.PrintToFile(os.Stdout)
+
+    os.MkdirAll("output/analyze", os.ModePerm)
+    out, err = os.Create(fmt.Sprintf("output/analyze/%v_analyze_%v.txt", time.Now().Unix(), filepath.Base(filePath)))
+    if err != nil {
+        log.Fatalf(err.Error())
+    }
+    defer out.Close()
+
+    report.PrintToFile(out)
+}
@@ -0,0 +1,264 @@
+package analyzer
+
+import (
+    "fmt"
+    "io"
+    "math"
+    "math/bits"
+    "sort"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+type (
+    // Analyzer characterises a trace in a single streaming pass
+    Analyzer struct {
+        requests    int
+        reads       int
+        writes      int
+        deletes     int
+        sequential  int
+        runs        int
+        previous    simulator.Key
+        inRun       bool
+        startTime   float64
+        endTime     float64
+
+        frequency   map[simulator.Key]int
+        stack       *StackDistance
+        reuse       Histogram
+        sizes       Histogram
+        sizeTotal   int
+        sizeMin     int
+        sizeMax     int
+    }
+
+    // Histogram counts values in power-of-two buckets: bucket 0 holds 0,
+    // bucket i holds [2^(i-1), 2^i)
+    Histogram struct {
+        Buckets     []int
+        Count       int
+    }
+
+    // Report holds the characteristics of an analysed trace
+    Report struct {
+        Requests        int
+        Skipped         int     // bad trace lines left out
+        Unique          int
+        Reads           int
+        Writes          int
+        Deletes         int
+        Duration        float64
+        OneHitWonders   int
+        Sequential      int     // requests to the address following the previous one
+        SequentialRuns  int     // maximal runs of sequential requests
+        ColdMisses      int     // first accesses, without a reuse distance
+        Reuse           Histogram
+        ZipfAlpha       float64
+        ZipfR2          float64
+        Sizes           Histogram
+        SizeMin         int
+        SizeMax         int
+        SizeMean        float64
+    }
+)
+
+func NewAnalyzer() *Analyzer {
+    return &Analyzer{
+        frequency:  make(map[simulator.Key]int),
+        stack:      NewStackDistance(),
+    }
+}
+
+func (h *Histogram) Add(value int) {
+    bucket := 0
+    if value > 0 {
+        bucket = bits.Len(uint(value))
+    }
+
+    for len(h.Buckets) <= bucket {
+        h.Buckets = append(h.Buckets, 0)
+    }
+    h.Buckets[bucket]++
+    h.Count++
+}
+
+// Bounds returns the value range [low, high) counted by a bucket
+func (h *Histogram) Bounds(bucket int) (low int, high int) {
+    if bucket == 0 {
+        return 0, 1
+    }
+    return 1 << (bucket - 1), 1 << bucket
+}
+
+func (a *Analyzer) Add(trace simulator.Trace) {
+    if a.requests == 0 {
+        a.startTime = trace.Timestamp
+        a.inRun = false
+    } else if trace.Key == a.previous.Next() {
+        a.sequential++
+        if !a.inRun {
+            a.runs++
+            a.inRun = true
+        }
+    } else {
+        a.inRun = false
+    }
+    a.endTime = trace.Timestamp
+    a.previous = trace.Key
+    a.requests++
+
+    switch trace.Op {
+    case simulator.OpWrite:
+        a.writes++
+    case simulator.OpDelete:
+        a.deletes++
+    default:
+        a.reads++
+    }
+
+    a.frequency[trace.Key]++
+
+    if distance, ok := a.stack.Access(trace.Key); ok {
+        a.reuse.Add(distance)
+    }
+
+    if trace.Size > 0 {
+        if a.sizes.Count == 0 || trace.Size < a.sizeMin {
+            a.sizeMin = trace.Size
+        }
+        if trace.Size > a.sizeMax {
+            a.sizeMax = trace.Size
+        }
+        a.sizeTotal += trace.Size
+        a.sizes.Add(trace.Size)
+    }
+}
+
+// Report summarises everything added so far
+func (a *Analyzer) Report() (report Report) {
+    report = Report{
+        Requests:       a.requests,
+        Unique:         len(a.frequency),
+        Reads:          a.reads,
+        Writes:         a.writes,
+        Deletes:        a.deletes,
+        Duration:       a.endTime - a.startTime,
+        Sequential:     a.sequential,
+        SequentialRuns: a.runs,
+        ColdMisses:     a.requests - a.reuse.Count,
+        Reuse:          a.reuse,
+        Sizes:          a.sizes,
+        SizeMin:        a.sizeMin,
+        SizeMax:        a.sizeMax,
+    }
+
+    if a.sizes.Count > 0 {
+        report.SizeMean = float64(a.sizeTotal) / float64(a.sizes.Count)
+    }
+
+    for _, count := range a.frequency {
+        if count == 1 {
+            report.OneHitWonders++
+        }
+    }
+
+    report.ZipfAlpha, report.ZipfR2 = fitZipf(a.frequency)
+
+    return report
+}
+
+// fitZipf fits frequency = C * rank^-alpha by least squares in log-log space
+// and returns alpha together with the coefficient of determination
+func fitZipf(frequency map[simulator.Key]int) (alpha float64, r2 float64) {
+    var (
+        counts                  []int = make([]int, 0, len(frequency))
+        sx, sy, sxx, sxy, syy   float64
+        n                       float64
+    )
+
+    if len(frequency) < 2 {
+        return 0, 0
+    }
+
+    for _, count := range frequency {
+        counts = append(counts, count)
+    }
+    sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+
+    for rank, count := range counts {
+        x := math.Log(float64(rank + 1))
+        y := math.Log(float64(count))
+        sx += x
+        sy += y
+        sxx += x * x
+        sxy += x * y
+        syy += y * y
+    }
+    n = float64(len(counts))
+
+    slope := (n * sxy - sx * sy) / (n * sxx - sx * sx)
+    varY := n * syy - sy * sy
+    if varY == 0 {
+        return -slope, 1
+    }
+    r := (n * sxy - sx * sy) / math.Sqrt((n * sxx - sx * sx) * varY)
+
+    return -slope, r * r
+}
+
+func ratio(part int, total int) float64 {
+    if total == 0 {
+        return 0
+    }
+    return float64(part) / float64(total) * 100
+}
+
+func (report Report) PrintToFile(file io.Writer) (err error) {
+    fmt.Fprintf(file, "requests: %d\n", report.Requests)
+    if report.Skipped > 0 {
+        fmt.Fprintf(file, "skipped lines: %d\n", report.Skipped)
+    }
+    fmt.Fprintf(file, "unique addresses: %d\n", report.Unique)
+    fmt.Fprintf(file, "reads: %d (%.4f%%)\n", report.Reads, ratio(report.Reads, report.Requests))
+    fmt.Fprintf(file, "writes: %d (%.4f%%)\n", report.Writes, ratio(report.Writes, report.Requests))
+    fmt.Fprintf(file, "deletes: %d (%.4f%%)\n", report.Deletes, ratio(report.Deletes, report.Requests))
+    fmt.Fprintf(file, "duration: %.4f s\n", report.Duration)
+    fmt.Fprintf(
+        file, "one-hit wonders: %d (%.4f%% of unique addresses)\n",
+        report.OneHitWonders, ratio(report.OneHitWonders, report.Unique),
+    )
+    fmt.Fprintf(
+        file, "sequential requests: %d (%.4f%%) in %d runs\n",
+        report.Sequential, ratio(report.Sequential, report.Requests), report.SequentialRuns,
+    )
+    fmt.Fprintf(file, "zipf alpha: %.4f (r2 %.4f)\n", report.ZipfAlpha, report.ZipfR2)
+
+    io.WriteString(file, "\nreuse distance histogram:\n")
+    fmt.Fprintf(file, "%12v %12v %10v\n", "distance <", "requests", "percent")
+    fmt.Fprintf(file, "%12v %12d %9.4f%%\n", "cold", report.ColdMisses, ratio(report.ColdMisses, report.Requests))
+    for bucket, count := range report.Reuse.Buckets {
+        _, high := report.Reuse.Bounds(bucket)
+        fmt.Fprintf(file, "%12d %12d %9.4f%%\n", high, count, ratio(count, report.Requests))
+    }
+
+    if report.Sizes.Count == 0 {
+        io.WriteString(file, "\nrequest sizes: not present in trace\n")
+        return nil
+    }
+
+    fmt.Fprintf(
+        file, "\nrequest sizes: min %d, mean %.2f, max %d bytes\n",
+        report.SizeMin, report.SizeMean, report.SizeMax,
+    )
+    fmt.Fprintf(file, "%12v %12v %10v\n", "size <", "requests", "percent")
+    for bucket, count := range report.Sizes.Buckets {
+        if count == 0 {
+            continue
+        }
+        _, high := report.Sizes.Bounds(bucket)
+        fmt.Fprintf(file, "%12d %12d %9.4f%%\n", high, count, ratio(count, report.Sizes.Count))
+    }
+
+    return nil
+}
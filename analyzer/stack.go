@@ -0,0 +1,90 @@
+package analyzer
+
+import (
+    "sort"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// StackDistance computes LRU stack (reuse) distances online: the number of
+// distinct addresses accessed since the previous access to an address. A
+// Fenwick tree marks the time of the latest access of every address; it is
+// compacted whenever it fills up, so memory stays proportional to the
+// number of unique addresses rather than to the trace length.
+type StackDistance struct {
+    last    map[simulator.Key]int   // address -> position of its latest access
+    tree    []int                   // Fenwick tree over positions, 1-based
+    time    int
+}
+
+func NewStackDistance() *StackDistance {
+    return &StackDistance{
+        last:   make(map[simulator.Key]int),
+        tree:   make([]int, 1024 + 1),
+        time:   0,
+    }
+}
+
+// Access records an access and returns its stack distance; ok is false on
+// the first access to addr
+func (s *StackDistance) Access(addr simulator.Key) (distance int, ok bool) {
+    if s.time + 1 >= len(s.tree) {
+        s.compact()
+    }
+    s.time++
+
+    if position, exists := s.last[addr]; exists {
+        distance = s.sum(s.time - 1) - s.sum(position)
+        s.add(position, -1)
+        ok = true
+    }
+
+    s.add(s.time, 1)
+    s.last[addr] = s.time
+
+    return distance, ok
+}
+
+// Unique returns the number of distinct addresses seen so far
+func (s *StackDistance) Unique() int {
+    return len(s.last)
+}
+
+func (s *StackDistance) add(position int, delta int) {
+    for ; position < len(s.tree); position += position & -position {
+        s.tree[position] += delta
+    }
+}
+
+func (s *StackDistance) sum(position int) (total int) {
+    for ; position > 0; position -= position & -position {
+        total += s.tree[position]
+    }
+    return total
+}
+
+// compact renumbers the live positions 1..n, keeping their order
+func (s *StackDistance) compact() {
+    var (
+        addrs   []simulator.Key = make([]simulator.Key, 0, len(s.last))
+        size    int             = 1024
+    )
+
+    for addr := range s.last {
+        addrs = append(addrs, addr)
+    }
+    sort.Slice(addrs, func(i, j int) bool {
+        return s.last[addrs[i]] < s.last[addrs[j]]
+    })
+
+    for size < 2 * len(addrs) {
+        size *= 2
+    }
+
+    s.tree = make([]int, size + 1)
+    for i, addr := range addrs {
+        s.last[addr] = i + 1
+        s.add(i + 1, 1)
+    }
+    s.time = len(addrs)
+}
@@ -1,8 +1,9 @@
 package arc
 
 import (
+    "encoding/gob"
 	"fmt"
-	"os"
+	"io"
 	"time"
 
 	"github.com/mohammadtauchid/golang-cache/v2/simulator"
@@ -11,8 +12,8 @@ import (
 
 type (
     Node struct {
-        lba int
-        op  string
+        lba simulator.Key
+        op  simulator.Op
     }
 
     ARC struct {
@@ -22,12 +23,33 @@ type (
         miss        int
         p           int // p is the number of pages in T1; adaptation parameter
         wc          int
+        evict       int
+        deleted     int
+        onEvict     func(lba simulator.Key) // notified of every evicted page
+        purgeGhosts bool // delete requests also drop the page from B1/B2
+        ghost       *simulator.GhostStats
 
         t1          *orderedmap.OrderedMap
         t2          *orderedmap.OrderedMap
         b1          *orderedmap.OrderedMap
         b2          *orderedmap.OrderedMap
     }
+
+    // state is what a snapshot saves of an ARC
+    state struct {
+        Maxlen      int
+        Hit         int
+        Miss        int
+        P           int
+        Wc          int
+        Evict       int
+        Deleted     int
+        Ghost       simulator.GhostStats
+        T1          []simulator.Entry
+        T2          []simulator.Entry
+        B1          []simulator.Entry
+        B2          []simulator.Entry
+    }
 )
 
 func NewARC(value int) *ARC {
@@ -38,6 +60,10 @@ func NewARC(value int) *ARC {
         miss:           0,
         p:              0,
         wc:             0,
+        evict:          0,
+        deleted:        0,
+        purgeGhosts:    false,
+        ghost:          simulator.NewGhostStats(),
         t1:             orderedmap.NewOrderedMap(),
         t2:             orderedmap.NewOrderedMap(),
         b1:             orderedmap.NewOrderedMap(),
@@ -46,8 +72,10 @@ func NewARC(value int) *ARC {
 }
 
 func (arc *ARC) Replace(data *Node) (err error) {
+    key := data.lba.MapKey()
+
     t1size := arc.t1.Len()
-    _, b2exist := arc.b2.Get(data.lba)
+    _, b2exist := arc.b2.Get(key)
     if t1size > 0 && (t1size > arc.p || (b2exist && t1size == arc.p)) {
         // move LRU of T1 to MRU of B1
         lruKey, lruVal, ok := arc.t1.GetFirst()
@@ -56,6 +84,8 @@ func (arc *ARC) Replace(data *Node) (err error) {
         }
         arc.t1.Delete(lruKey)
         arc.b1.Set(lruKey, lruVal)
+        arc.evicted(lruKey)
+        arc.ghost.T1Evictions++
     } else {
         // move LRU of T2 to MRU of B2
         lruKey, lruVal, ok := arc.t2.GetFirst()
@@ -64,11 +94,15 @@ func (arc *ARC) Replace(data *Node) (err error) {
         }
         arc.t2.Delete(lruKey)
         arc.b2.Set(lruKey, lruVal)
+        arc.evicted(lruKey)
+        arc.ghost.T2Evictions++
     }
     return nil
 }
 
 func (arc *ARC) Put(data *Node) (exists bool) {
+    key := data.lba.MapKey()
+
     // length of list
     t1size := arc.t1.Len()
     t2size := arc.t2.Len()
@@ -76,13 +110,14 @@ func (arc *ARC) Put(data *Node) (exists bool) {
     b2size := arc.b2.Len()
 
     // first case: data is in T1 or T2
-    if _, ok := arc.t1.Get(data.lba); ok {
-        arc.t1.Delete(data.lba)
-        arc.t2.Set(data.lba, data.op)
+    if _, ok := arc.t1.Get(key); ok {
+        arc.t1.Delete(key)
+        arc.t2.Set(key, data.op)
         arc.hit++
+        arc.ghost.Promotions++
         return true
-    } else if _, ok := arc.t2.Get(data.lba); ok {
-        arc.t2.MoveLast(data.lba)
+    } else if _, ok := arc.t2.Get(key); ok {
+        arc.t2.MoveLast(key)
         arc.hit++
         return true
     }
@@ -90,7 +125,9 @@ func (arc *ARC) Put(data *Node) (exists bool) {
     arc.wc++
 
     // second case: data is in B1
-    if _, ok := arc.b1.Get(data.lba); ok {
+    if _, ok := arc.b1.Get(key); ok {
+        arc.ghost.B1Hits++
+
         // adaptation
         delta := 1
         if b1size < b2size {
@@ -110,15 +147,17 @@ func (arc *ARC) Put(data *Node) (exists bool) {
         }
 
         // move data from B1 to T2
-        arc.b1.Delete(data.lba)
-        arc.t2.Set(data.lba, data.op)
+        arc.b1.Delete(key)
+        arc.t2.Set(key, data.op)
 
         arc.miss++
         return false
     }
 
     // third case: data is in B2
-    if _, ok := arc.b2.Get(data.lba); ok {
+    if _, ok := arc.b2.Get(key); ok {
+        arc.ghost.B2Hits++
+
         // adaptation
         delta := 1
         if b2size < b1size {
@@ -138,8 +177,8 @@ func (arc *ARC) Put(data *Node) (exists bool) {
         }
 
         // move data from B2 to T2
-        arc.b2.Delete(data.lba)
-        arc.t2.Set(data.lba, data.op)
+        arc.b2.Delete(key)
+        arc.t2.Set(key, data.op)
 
         arc.miss++
         return false
@@ -155,6 +194,8 @@ func (arc *ARC) Put(data *Node) (exists bool) {
             // B1 is empty
             key, _, _ := arc.t1.GetFirst()
             arc.t1.Delete(key)
+            arc.evicted(key)
+            arc.ghost.T1Evictions++
         }
     }
     // * second case: T1 and B1 has less than c pages
@@ -173,27 +214,206 @@ func (arc *ARC) Put(data *Node) (exists bool) {
     }
 
     arc.miss++
-    arc.t1.Set(data.lba, data.op)
+    arc.t1.Set(key, data.op)
 
     return false
 }
 
+// SetPurgeGhosts makes delete requests also drop the page from the ghost
+// lists, so a later request for it is not taken as a ghost hit
+func (arc *ARC) SetPurgeGhosts(purge bool) {
+    arc.purgeGhosts = purge
+}
+
+// Remove drops lba from T1 or T2, returning whether it was cached
+func (arc *ARC) Remove(lba simulator.Key) (removed bool) {
+    key := lba.MapKey()
+
+    if _, ok := arc.t1.Get(key); ok {
+        arc.t1.Delete(key)
+        removed = true
+    } else if _, ok := arc.t2.Get(key); ok {
+        arc.t2.Delete(key)
+        removed = true
+    }
+
+    if arc.purgeGhosts {
+        arc.b1.Delete(key)
+        arc.b2.Delete(key)
+    }
+
+    return removed
+}
+
+// Contains reports whether lba is cached, without updating the lists
+func (arc *ARC) Contains(lba simulator.Key) bool {
+    key := lba.MapKey()
+
+    if _, ok := arc.t1.Get(key); ok {
+        return true
+    }
+    _, ok := arc.t2.Get(key)
+    return ok
+}
+
+// Keys returns the cached pages of T1 then T2, each from the least to the
+// most recently used
+func (arc *ARC) Keys() (keys []simulator.Key) {
+    for _, list := range []*orderedmap.OrderedMap{arc.t1, arc.t2} {
+        iter := list.Iter()
+        for key, _, ok := iter.Next(); ok; key, _, ok = iter.Next() {
+            keys = append(keys, simulator.KeyOf(key))
+        }
+    }
+    return keys
+}
+
+// OnEvict registers fn to be called with every page evicted from the cache
+func (arc *ARC) OnEvict(fn func(lba simulator.Key)) {
+    arc.onEvict = fn
+}
+
+func (arc *ARC) evicted(key interface{}) {
+    // an empty list, as in a cache of no pages, has nothing to evict
+    if key == nil {
+        return
+    }
+    arc.evict++
+    if arc.onEvict != nil {
+        arc.onEvict(simulator.KeyOf(key))
+    }
+}
+
+// Resize changes the capacity of the cache. When it shrinks, pages are
+// replaced into the ghost lists as REPLACE would, and the ghost lists are
+// trimmed to the bounds of the new size.
+func (arc *ARC) Resize(value int) {
+    arc.maxlen = value
+    if arc.p > value {
+        arc.p = value
+    }
+
+    for arc.t1.Len() + arc.t2.Len() > value {
+        if arc.t1.Len() > 0 && (arc.t1.Len() > arc.p || arc.t2.Len() == 0) {
+            lruKey, lruVal, _ := arc.t1.PopFirst()
+            arc.b1.Set(lruKey, lruVal)
+            arc.evicted(lruKey)
+            arc.ghost.T1Evictions++
+        } else {
+            lruKey, lruVal, _ := arc.t2.PopFirst()
+            arc.b2.Set(lruKey, lruVal)
+            arc.evicted(lruKey)
+            arc.ghost.T2Evictions++
+        }
+    }
+
+    for arc.t1.Len() + arc.b1.Len() > value && arc.b1.Len() > 0 {
+        arc.b1.PopFirst()
+    }
+    for arc.t1.Len() + arc.t2.Len() + arc.b1.Len() + arc.b2.Len() > 2 * value && arc.b2.Len() > 0 {
+        arc.b2.PopFirst()
+    }
+}
+
 func (arc *ARC) Get(trace simulator.Trace) (err error) {
+    if trace.Op == simulator.OpDelete {
+        if arc.Remove(trace.Key) {
+            arc.deleted++
+        }
+        return nil
+    }
+
     obj := new(Node)
-    obj.lba = trace.Addr
+    obj.lba = trace.Key
     obj.op = trace.Op
     arc.Put(obj)
+    arc.ghost.ObserveP(arc.p, arc.maxlen)
+
+    return nil
+}
+
+func (arc *ARC) Stats() simulator.Stats {
+    return simulator.Stats{
+        Hit:        arc.hit,
+        Miss:       arc.miss,
+        Write:      arc.wc,
+        Eviction:   arc.evict,
+        Delete:     arc.deleted,
+        Occupancy:  arc.t1.Len() + arc.t2.Len(),
+        Capacity:   arc.maxlen,
+    }
+}
+
+// GhostStats returns the ghost list counters and the current list sizes
+func (arc *ARC) GhostStats() simulator.GhostStats {
+    ghost := *arc.ghost
+    ghost.PHistogram = append([]int(nil), arc.ghost.PHistogram...)
+    ghost.P = arc.p
+    ghost.T1 = arc.t1.Len()
+    ghost.T2 = arc.t2.Len()
+    ghost.B1 = arc.b1.Len()
+    ghost.B2 = arc.b2.Len()
+
+    return ghost
+}
+
+// Details returns the ghost list analytics for the results file
+func (arc *ARC) Details() interface{} {
+    return arc.GhostStats()
+}
+
+func (arc *ARC) PrintToFile(file io.Writer, elapsed time.Duration) (err error) {
+    fmt.Fprintf(file, "cache size: %d\n", arc.maxlen)
+    fmt.Fprintf(file, "cache hit: %d\n", arc.hit)
+    fmt.Fprintf(file, "cache miss: %d\n", arc.miss)
+    fmt.Fprintf(file, "cache hit ratio: %.4f%%\n", float64(arc.hit) / float64(arc.hit + arc.miss) * 100)
+    fmt.Fprintf(file, "write count: %d\n", arc.wc)
+    fmt.Fprintf(file, "delete count: %d\n", arc.deleted)
+    ghost := arc.GhostStats()
+    ghost.PrintToFile(file)
+    fmt.Fprintf(file, "time execution: %8.4f\n", elapsed.Seconds())
 
     return nil
 }
 
-func (arc *ARC) PrintToFile(file *os.File, start time.Time) (err error) {
-    file.WriteString(fmt.Sprintf("cache size: %d\n", arc.maxlen))
-    file.WriteString(fmt.Sprintf("cache hit: %d\n", arc.hit))
-    file.WriteString(fmt.Sprintf("cache miss: %d\n", arc.miss))
-    file.WriteString(fmt.Sprintf("cache hit ratio: %.4f%%\n", float64(arc.hit) / float64(arc.hit + arc.miss) * 100))
-    file.WriteString(fmt.Sprintf("write count: %d\n", arc.wc))
-    file.WriteString(fmt.Sprintf("time execution: %8.4f\n", time.Since(start).Seconds()))
+// Checkpoint saves the lists, p and the counters
+func (arc *ARC) Checkpoint(enc *gob.Encoder) (err error) {
+    return enc.Encode(state{
+        Maxlen:     arc.maxlen,
+        Hit:        arc.hit,
+        Miss:       arc.miss,
+        P:          arc.p,
+        Wc:         arc.wc,
+        Evict:      arc.evict,
+        Deleted:    arc.deleted,
+        Ghost:      *arc.ghost,
+        T1:         simulator.SaveList(arc.t1),
+        T2:         simulator.SaveList(arc.t2),
+        B1:         simulator.SaveList(arc.b1),
+        B2:         simulator.SaveList(arc.b2),
+    })
+}
+
+// Restore replaces the state of the cache by one saved by Checkpoint
+func (arc *ARC) Restore(dec *gob.Decoder) (err error) {
+    var s state
+
+    if err = dec.Decode(&s); err != nil {
+        return err
+    }
+
+    arc.maxlen = s.Maxlen
+    arc.hit = s.Hit
+    arc.miss = s.Miss
+    arc.p = s.P
+    arc.wc = s.Wc
+    arc.evict = s.Evict
+    arc.deleted = s.Deleted
+    arc.ghost = &s.Ghost
+    arc.t1 = simulator.LoadList(s.T1)
+    arc.t2 = simulator.LoadList(s.T2)
+    arc.b1 = simulator.LoadList(s.B1)
+    arc.b2 = simulator.LoadList(s.B2)
 
     return nil
-}
\ No newline at end of file
+}
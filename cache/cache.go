@@ -0,0 +1,309 @@
+package cache
+
+import (
+    "fmt"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/mohammadtauchid/golang-cache/v2/arc"
+    "github.com/mohammadtauchid/golang-cache/v2/larc"
+    "github.com/mohammadtauchid/golang-cache/v2/lru"
+    "github.com/mohammadtauchid/golang-cache/v2/marc"
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// Algorithms lists the policies accepted by New
+var Algorithms = []string{"lru", "arc", "larc", "marc"}
+
+type (
+    // entry is a cached value with the key it was stored under
+    entry struct {
+        key     string
+        value   interface{}
+        expires time.Time // zero when the entry does not expire
+    }
+
+    // Cache is an in-process key/value cache of a fixed number of entries.
+    // The replacement decisions are taken by one of the simulated policies,
+    // which only sees the hashed keys; the values are kept alongside and
+    // dropped when the policy evicts their key. It is safe for concurrent
+    // use.
+    //
+    // The filters of larc and marc refuse keys they have not seen before,
+    // explicit sets included: the first Set of a key may not store it, and
+    // a Get right after then misses. Set reports it; lru and arc store
+    // every value.
+    Cache struct {
+        mu          sync.Mutex
+        algorithm   string
+        capacity    int
+        policy      simulator.Policy
+        entries     map[simulator.Key]*entry
+
+        hit         int
+        miss        int
+        wc          int
+        evict       int
+        deleted     int
+        expired     int
+    }
+)
+
+// NewPolicy creates a policy of the given algorithm holding capacity pages
+func NewPolicy(algorithm string, capacity int) (policy simulator.Policy, err error) {
+    switch strings.ToLower(algorithm) {
+    case "lru":
+        policy = lru.NewLRU(capacity)
+    case "arc":
+        policy = arc.NewARC(capacity)
+    case "larc":
+        policy = larc.NewLARC(capacity)
+    case "marc":
+        policy = marc.NewMARC(capacity)
+    default:
+        return nil, fmt.Errorf("algorithm %v not supported, expected one of %v", algorithm, Algorithms)
+    }
+
+    return policy, nil
+}
+
+// New creates a cache of capacity entries managed by the given algorithm
+func New(algorithm string, capacity int) (c *Cache, err error) {
+    if capacity < 1 {
+        return nil, fmt.Errorf("invalid cache capacity %d", capacity)
+    }
+
+    c = &Cache{
+        algorithm:  strings.ToLower(algorithm),
+        capacity:   capacity,
+    }
+    if err = c.reset(); err != nil {
+        return nil, err
+    }
+
+    return c, nil
+}
+
+// reset empties the cache with a new policy, keeping the counters
+func (c *Cache) reset() (err error) {
+    policy, err := NewPolicy(c.algorithm, c.capacity)
+    if err != nil {
+        return err
+    }
+    c.use(policy, make(map[simulator.Key]*entry))
+
+    return nil
+}
+
+// use installs a policy and the entries it caches
+func (c *Cache) use(policy simulator.Policy, entries map[simulator.Key]*entry) {
+    c.policy = policy
+    c.entries = entries
+    c.policy.OnEvict(func(key simulator.Key) {
+        if _, ok := c.entries[key]; ok {
+            delete(c.entries, key)
+            c.evict++
+        }
+    })
+}
+
+// lookup returns the entry of key, dropping it when it has expired
+func (c *Cache) lookup(key string) (k simulator.Key, e *entry, ok bool) {
+    k = simulator.StringKey(key)
+    e, ok = c.entries[k]
+    if !ok || e.key != key {
+        return k, nil, false
+    }
+
+    if !e.expires.IsZero() && !time.Now().Before(e.expires) {
+        c.policy.Remove(k)
+        delete(c.entries, k)
+        c.expired++
+        return k, nil, false
+    }
+
+    return k, e, true
+}
+
+// Get returns the value stored under key
+func (c *Cache) Get(key string) (value interface{}, ok bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    k, e, ok := c.lookup(key)
+    if !ok {
+        // a miss is not shown to the policy, which would cache the key
+        // without a value
+        c.miss++
+        return nil, false
+    }
+
+    c.policy.Get(simulator.Trace{Key: k, Op: simulator.OpRead})
+    c.hit++
+
+    return e.value, true
+}
+
+// Set stores value under key, returning whether the policy admitted it:
+// the filters of larc and marc only admit keys that were seen before
+func (c *Cache) Set(key string, value interface{}) (stored bool) {
+    return c.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL stores value under key for ttl, or without expiry when ttl
+// is not positive
+func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) (stored bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    k, _, _ := c.lookup(key)
+    return c.set(k, key, value, ttl)
+}
+
+// Add stores value under key for ttl unless key is already cached,
+// returning whether it was stored
+func (c *Cache) Add(key string, value interface{}, ttl time.Duration) (stored bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    k, _, ok := c.lookup(key)
+    if ok {
+        return false
+    }
+    return c.set(k, key, value, ttl)
+}
+
+func (c *Cache) set(k simulator.Key, key string, value interface{}, ttl time.Duration) (stored bool) {
+    c.wc++
+
+    // entries must be in place before the policy evicts for the new key
+    e, exists := c.entries[k]
+    if !exists {
+        e = &entry{}
+        c.entries[k] = e
+    }
+    e.key, e.value, e.expires = key, value, time.Time{}
+    if ttl > 0 {
+        e.expires = time.Now().Add(ttl)
+    }
+
+    c.policy.Get(simulator.Trace{Key: k, Op: simulator.OpWrite})
+    if !c.policy.Contains(k) {
+        delete(c.entries, k)
+        return false
+    }
+
+    return true
+}
+
+// Delete removes key, returning whether it was cached
+func (c *Cache) Delete(key string) (deleted bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    k, _, ok := c.lookup(key)
+    if !ok {
+        return false
+    }
+
+    // a delete request, so that the policy counts it
+    c.policy.Get(simulator.Trace{Key: k, Op: simulator.OpDelete})
+    delete(c.entries, k)
+    c.deleted++
+
+    return true
+}
+
+// Contains reports whether key is cached, without counting a request
+func (c *Cache) Contains(key string) bool {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    _, _, ok := c.lookup(key)
+    return ok
+}
+
+// TTL returns the time left before key expires, zero when it does not
+func (c *Cache) TTL(key string) (ttl time.Duration, ok bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    _, e, ok := c.lookup(key)
+    if !ok || e.expires.IsZero() {
+        return 0, ok
+    }
+    return time.Until(e.expires), true
+}
+
+// Touch sets the time to live of key without counting a request,
+// returning whether it is cached
+func (c *Cache) Touch(key string, ttl time.Duration) (ok bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    _, e, ok := c.lookup(key)
+    if !ok {
+        return false
+    }
+
+    e.expires = time.Time{}
+    if ttl > 0 {
+        e.expires = time.Now().Add(ttl)
+    }
+    return true
+}
+
+// Len returns the number of cached entries
+func (c *Cache) Len() int {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    return len(c.entries)
+}
+
+// Flush removes every entry; the policy starts over, the counters are kept
+func (c *Cache) Flush() {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    c.reset()
+}
+
+// Algorithm returns the name of the replacement policy
+func (c *Cache) Algorithm() string {
+    return c.algorithm
+}
+
+// Expired returns the number of entries dropped because they expired
+func (c *Cache) Expired() int {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    return c.expired
+}
+
+// Stats returns the requests served by the cache
+func (c *Cache) Stats() simulator.Stats {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    return simulator.Stats{
+        Hit:        c.hit,
+        Miss:       c.miss,
+        Write:      c.wc,
+        Eviction:   c.evict,
+        Delete:     c.deleted,
+        Occupancy:  len(c.entries),
+        Capacity:   c.capacity,
+    }
+}
+
+// PolicyStats returns the counters of the replacement policy, which also
+// counts writes of cached keys as hits
+func (c *Cache) PolicyStats() simulator.Stats {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    return c.policy.Stats()
+}
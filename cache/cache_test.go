@@ -0,0 +1,141 @@
+package cache
+
+import (
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestSetGetDelete(t *testing.T) {
+    c, err := New("lru", 10)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if !c.Set("foo", "bar") {
+        t.Fatal("lru refused a value")
+    }
+    if value, ok := c.Get("foo"); !ok || value != "bar" {
+        t.Fatalf("got %v, %v, expected bar", value, ok)
+    }
+    if _, ok := c.Get("missing"); ok {
+        t.Fatal("got a value for a missing key")
+    }
+
+    if c.Add("foo", "baz", 0) {
+        t.Fatal("added a cached key")
+    }
+    if !c.Add("new", "baz", 0) || !c.Contains("new") {
+        t.Fatal("could not add a new key")
+    }
+
+    if !c.Delete("foo") || c.Delete("foo") {
+        t.Fatal("expected a single deletion")
+    }
+    if _, ok := c.Get("foo"); ok {
+        t.Fatal("got a deleted value")
+    }
+
+    stats := c.Stats()
+    if stats.Hit != 1 || stats.Miss != 2 || stats.Write != 2 || stats.Delete != 1 || stats.Occupancy != 1 {
+        t.Fatalf("unexpected counters %+v", stats)
+    }
+    if policy := c.PolicyStats(); policy.Delete != 1 || policy.Occupancy != 1 {
+        t.Fatalf("unexpected policy counters %+v", policy)
+    }
+}
+
+func TestEviction(t *testing.T) {
+    c, err := New("lru", 10)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    for i := 0; i < 15; i++ {
+        c.Set(fmt.Sprint("key", i), i)
+    }
+
+    if c.Len() != 10 || c.Stats().Eviction != 5 {
+        t.Fatalf("%d entries and %d evictions, expected 10 and 5", c.Len(), c.Stats().Eviction)
+    }
+    if _, ok := c.Get("key0"); ok {
+        t.Fatal("least recently used key was not evicted")
+    }
+    if value, ok := c.Get("key14"); !ok || value != 14 {
+        t.Fatalf("got %v, %v, expected 14", value, ok)
+    }
+
+    c.Flush()
+    if c.Len() != 0 || c.Contains("key14") {
+        t.Fatal("flush kept entries")
+    }
+}
+
+func TestTTL(t *testing.T) {
+    c, err := New("arc", 10)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    c.SetWithTTL("short", 1, 50 * time.Millisecond)
+    c.SetWithTTL("long", 2, time.Hour)
+    c.Set("forever", 3)
+
+    if ttl, ok := c.TTL("long"); !ok || ttl <= 59 * time.Minute {
+        t.Fatalf("got %v, %v, expected about an hour", ttl, ok)
+    }
+    if ttl, ok := c.TTL("forever"); !ok || ttl != 0 {
+        t.Fatalf("got %v, %v, expected no expiry", ttl, ok)
+    }
+
+    if !c.Touch("long", 50 * time.Millisecond) {
+        t.Fatal("could not touch a cached key")
+    }
+    time.Sleep(100 * time.Millisecond)
+
+    for _, key := range []string{"short", "long"} {
+        if _, ok := c.Get(key); ok {
+            t.Fatalf("%v did not expire", key)
+        }
+    }
+    if _, ok := c.Get("forever"); !ok {
+        t.Fatal("a value without expiry expired")
+    }
+    if c.Expired() != 2 || c.Len() != 1 {
+        t.Fatalf("%d expired and %d entries, expected 2 and 1", c.Expired(), c.Len())
+    }
+}
+
+// TestFilteredSet checks that values refused by the filters of larc and
+// marc are reported by Set and not returned by Get
+func TestFilteredSet(t *testing.T) {
+    c, err := New("larc", 10)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if c.Set("foo", "bar") {
+        t.Fatal("larc stored a key it had not seen before")
+    }
+    if _, ok := c.Get("foo"); ok {
+        t.Fatal("got a refused value")
+    }
+    if !c.Set("foo", "bar") {
+        t.Fatal("larc refused a key seen before")
+    }
+    if value, ok := c.Get("foo"); !ok || value != "bar" {
+        t.Fatalf("got %v, %v, expected bar", value, ok)
+    }
+
+    c, err = New("marc", 10)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < 20; i++ {
+        key := fmt.Sprint("key", i % 5)
+        stored := c.Set(key, i)
+        if _, ok := c.Get(key); ok != stored {
+            t.Fatalf("set of %v returned %v, get found it: %v", key, stored, ok)
+        }
+    }
+}
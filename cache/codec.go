@@ -0,0 +1,54 @@
+package cache
+
+import (
+    "fmt"
+)
+
+// Codec converts the values of a cache to and from bytes for snapshots
+type Codec interface {
+    // Name identifies the codec in snapshots, which are only loaded with
+    // the codec they were saved with
+    Name() string
+    Encode(value interface{}) ([]byte, error)
+    Decode(data []byte) (interface{}, error)
+}
+
+type (
+    // BytesCodec stores []byte values as they are
+    BytesCodec struct{}
+
+    // StringCodec stores string values as their bytes
+    StringCodec struct{}
+)
+
+func (BytesCodec) Name() string {
+    return "bytes"
+}
+
+func (BytesCodec) Encode(value interface{}) ([]byte, error) {
+    data, ok := value.([]byte)
+    if !ok {
+        return nil, fmt.Errorf("bytes codec cannot encode a %T value", value)
+    }
+    return data, nil
+}
+
+func (BytesCodec) Decode(data []byte) (interface{}, error) {
+    return append([]byte(nil), data...), nil
+}
+
+func (StringCodec) Name() string {
+    return "string"
+}
+
+func (StringCodec) Encode(value interface{}) ([]byte, error) {
+    s, ok := value.(string)
+    if !ok {
+        return nil, fmt.Errorf("string codec cannot encode a %T value", value)
+    }
+    return []byte(s), nil
+}
+
+func (StringCodec) Decode(data []byte) (interface{}, error) {
+    return string(data), nil
+}
@@ -0,0 +1,278 @@
+package cache
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "encoding/gob"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "os"
+    "path/filepath"
+    "time"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+const (
+    // SnapshotMagic starts every cache snapshot
+    SnapshotMagic = "GCCACHE\x00"
+    // SnapshotVersion is bumped whenever the layout of a snapshot changes
+    SnapshotVersion = 2
+)
+
+var (
+    // ErrNotSnapshot is returned for files that are not cache snapshots
+    ErrNotSnapshot = errors.New("not a cache snapshot")
+    // ErrVersion is returned for snapshots of another layout version
+    ErrVersion = errors.New("unsupported cache snapshot version")
+    // ErrCorrupt is returned for truncated or damaged snapshots
+    ErrCorrupt = errors.New("corrupted cache snapshot")
+    // ErrMismatch is returned for snapshots of another algorithm, capacity
+    // or codec than the cache they are loaded into
+    ErrMismatch = errors.New("cache snapshot does not match the cache")
+)
+
+type (
+    // snapshotHeader describes the cache a snapshot was taken from
+    snapshotHeader struct {
+        Algorithm   string
+        Capacity    int
+        Codec       string
+        Policy      int // simulator.SnapshotVersion of the policy state
+
+        Hit         int
+        Miss        int
+        Wc          int
+        Evict       int
+        Deleted     int
+        Expired     int
+        Entries     int
+    }
+
+    // snapshotEntry is a cached value, encoded by the codec
+    snapshotEntry struct {
+        Key     string
+        Value   []byte
+        Expires int64 // unix nanoseconds, zero when it does not expire
+    }
+)
+
+// Save writes the entries, the policy state and the counters of the cache
+// to path. The file is replaced atomically, so a crash while saving keeps
+// the previous snapshot.
+func (c *Cache) Save(path string, codec Codec) (err error) {
+    file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path) + ".*")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(file.Name())
+
+    writer := bufio.NewWriter(file)
+    err = c.Export(writer, codec)
+    if err == nil {
+        err = writer.Flush()
+    }
+    if err == nil {
+        err = file.Sync()
+    }
+    if closeErr := file.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        return err
+    }
+
+    return os.Rename(file.Name(), path)
+}
+
+// Export writes a snapshot of the cache: the magic, the version, the
+// CRC-32 and length of the payload, and the payload
+func (c *Cache) Export(w io.Writer, codec Codec) (err error) {
+    var payload bytes.Buffer
+
+    c.mu.Lock()
+    err = c.encode(&payload, codec)
+    c.mu.Unlock()
+    if err != nil {
+        return err
+    }
+
+    var header [16]byte
+    binary.LittleEndian.PutUint32(header[0:], SnapshotVersion)
+    binary.LittleEndian.PutUint32(header[4:], crc32.ChecksumIEEE(payload.Bytes()))
+    binary.LittleEndian.PutUint64(header[8:], uint64(payload.Len()))
+
+    if _, err = io.WriteString(w, SnapshotMagic); err != nil {
+        return err
+    }
+    if _, err = w.Write(header[:]); err != nil {
+        return err
+    }
+    _, err = w.Write(payload.Bytes())
+
+    return err
+}
+
+func (c *Cache) encode(w io.Writer, codec Codec) (err error) {
+    checkpointer, ok := c.policy.(simulator.Checkpointer)
+    if !ok {
+        return fmt.Errorf("%v caches cannot be saved", c.algorithm)
+    }
+
+    enc := gob.NewEncoder(w)
+    err = enc.Encode(snapshotHeader{
+        Algorithm:  c.algorithm,
+        Capacity:   c.capacity,
+        Codec:      codec.Name(),
+        Policy:     simulator.SnapshotVersion,
+        Hit:        c.hit,
+        Miss:       c.miss,
+        Wc:         c.wc,
+        Evict:      c.evict,
+        Deleted:    c.deleted,
+        Expired:    c.expired,
+        Entries:    len(c.entries),
+    })
+    if err != nil {
+        return err
+    }
+    if err = checkpointer.Checkpoint(enc); err != nil {
+        return err
+    }
+
+    for _, e := range c.entries {
+        value, err := codec.Encode(e.value)
+        if err != nil {
+            return fmt.Errorf("cannot save the value of %q: %w", e.key, err)
+        }
+        se := snapshotEntry{Key: e.key, Value: value}
+        if !e.expires.IsZero() {
+            se.Expires = e.expires.UnixNano()
+        }
+        if err = enc.Encode(se); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// Load replaces the contents of the cache by a snapshot saved by Save with
+// the same algorithm, capacity and codec. Nothing changes when the
+// snapshot is rejected.
+func (c *Cache) Load(path string, codec Codec) (err error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    if err = c.Import(bufio.NewReader(file), codec); err != nil {
+        return fmt.Errorf("%v: %w", path, err)
+    }
+    return nil
+}
+
+// Import replaces the contents of the cache by a snapshot written by
+// Export
+func (c *Cache) Import(r io.Reader, codec Codec) (err error) {
+    var header [24]byte
+
+    if _, err = io.ReadFull(r, header[:]); err != nil {
+        return ErrNotSnapshot
+    }
+    if string(header[:8]) != SnapshotMagic {
+        return ErrNotSnapshot
+    }
+    if version := binary.LittleEndian.Uint32(header[8:]); version != SnapshotVersion {
+        return fmt.Errorf("%w %d, expected %d", ErrVersion, version, SnapshotVersion)
+    }
+    checksum := binary.LittleEndian.Uint32(header[12:])
+    length := binary.LittleEndian.Uint64(header[16:])
+
+    // the length is not trusted before the checksum is verified
+    if length > 1 << 62 {
+        return ErrCorrupt
+    }
+    payload, err := io.ReadAll(io.LimitReader(r, int64(length)))
+    if err != nil {
+        return err
+    }
+    if uint64(len(payload)) != length || crc32.ChecksumIEEE(payload) != checksum {
+        return ErrCorrupt
+    }
+
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    return c.decode(bytes.NewReader(payload), codec)
+}
+
+func (c *Cache) decode(r io.Reader, codec Codec) (err error) {
+    var s snapshotHeader
+
+    dec := gob.NewDecoder(r)
+    if err = dec.Decode(&s); err != nil {
+        return ErrCorrupt
+    }
+
+    switch {
+    case s.Policy != simulator.SnapshotVersion:
+        return fmt.Errorf("%w: policy state version %d, expected %d", ErrVersion, s.Policy, simulator.SnapshotVersion)
+    case s.Algorithm != c.algorithm || s.Capacity != c.capacity:
+        return fmt.Errorf(
+            "%w: snapshot of %v with %d entries, cache of %v with %d entries",
+            ErrMismatch, s.Algorithm, s.Capacity, c.algorithm, c.capacity,
+        )
+    case s.Codec != codec.Name():
+        return fmt.Errorf("%w: values saved with the %v codec, loaded with %v", ErrMismatch, s.Codec, codec.Name())
+    }
+
+    policy, err := NewPolicy(c.algorithm, c.capacity)
+    if err != nil {
+        return err
+    }
+    checkpointer, ok := policy.(simulator.Checkpointer)
+    if !ok {
+        return fmt.Errorf("%v caches cannot be loaded", c.algorithm)
+    }
+    if err = checkpointer.Restore(dec); err != nil {
+        return ErrCorrupt
+    }
+
+    entries := make(map[simulator.Key]*entry, s.Entries)
+    for i := 0; i < s.Entries; i++ {
+        var se snapshotEntry
+        if err = dec.Decode(&se); err != nil {
+            return ErrCorrupt
+        }
+        value, err := codec.Decode(se.Value)
+        if err != nil {
+            return fmt.Errorf("%w: value of %q: %v", ErrCorrupt, se.Key, err)
+        }
+        e := &entry{key: se.Key, value: value}
+        if se.Expires != 0 {
+            // entries that expired meanwhile are dropped on their next use
+            e.expires = time.Unix(0, se.Expires)
+        }
+        entries[simulator.StringKey(se.Key)] = e
+    }
+
+    // the policy must cache exactly the saved entries
+    if policy.Stats().Occupancy != len(entries) {
+        return ErrCorrupt
+    }
+    for k := range entries {
+        if !policy.Contains(k) {
+            return ErrCorrupt
+        }
+    }
+
+    c.use(policy, entries)
+    c.hit, c.miss, c.wc, c.evict, c.deleted, c.expired = s.Hit, s.Miss, s.Wc, s.Evict, s.Deleted, s.Expired
+
+    return nil
+}
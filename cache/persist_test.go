@@ -0,0 +1,151 @@
+package cache
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+// filled returns a cache of algorithm holding a few values, some expiring
+func filled(t *testing.T, algorithm string) *Cache {
+    t.Helper()
+
+    c, err := New(algorithm, 100)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < 150; i++ {
+        key := fmt.Sprint("key", i % 60)
+        c.Get(key)
+        c.Set(key, []byte(key))
+    }
+    c.SetWithTTL("key1", []byte("key1"), 50 * time.Millisecond)
+    c.SetWithTTL("key2", []byte("key2"), time.Hour)
+
+    return c
+}
+
+func export(t *testing.T, c *Cache, codec Codec) []byte {
+    t.Helper()
+
+    var b bytes.Buffer
+    if err := c.Export(&b, codec); err != nil {
+        t.Fatal(err)
+    }
+    return b.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+    for _, algorithm := range Algorithms {
+        c := filled(t, algorithm)
+        path := filepath.Join(t.TempDir(), "cache.snapshot")
+        if err := c.Save(path, BytesCodec{}); err != nil {
+            t.Fatalf("%v: %v", algorithm, err)
+        }
+
+        loaded, err := New(algorithm, 100)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if err = loaded.Load(path, BytesCodec{}); err != nil {
+            t.Fatalf("%v: %v", algorithm, err)
+        }
+
+        if loaded.Stats() != c.Stats() || loaded.PolicyStats() != c.PolicyStats() {
+            t.Errorf("%v: loaded %+v, expected %+v", algorithm, loaded.Stats(), c.Stats())
+        }
+        for i := 0; i < 60; i++ {
+            key := fmt.Sprint("key", i)
+            if c.Contains(key) != loaded.Contains(key) {
+                t.Errorf("%v: %v cached %v, expected %v", algorithm, key, loaded.Contains(key), c.Contains(key))
+            }
+        }
+        value, ok := loaded.Get("key2")
+        if _, cached := c.Get("key2"); ok != cached || (ok && string(value.([]byte)) != "key2") {
+            t.Errorf("%v: got %q, %v, expected key2, %v", algorithm, value, ok, cached)
+        }
+        if ttl, ok := loaded.TTL("key2"); ok && ttl <= 59 * time.Minute {
+            t.Errorf("%v: time to live %v, expected about an hour", algorithm, ttl)
+        }
+
+        // the policies go on taking the same decisions
+        for i := 0; i < 200; i++ {
+            key := fmt.Sprint("next", i % 80)
+            if c.Set(key, []byte(key)) != loaded.Set(key, []byte(key)) {
+                t.Fatalf("%v: the loaded cache diverged at %v", algorithm, key)
+            }
+        }
+    }
+}
+
+func TestExpiryAcrossLoad(t *testing.T) {
+    c := filled(t, "lru")
+    data := export(t, c, BytesCodec{})
+
+    time.Sleep(100 * time.Millisecond)
+
+    loaded, _ := New("lru", 100)
+    if err := loaded.Import(bytes.NewReader(data), BytesCodec{}); err != nil {
+        t.Fatal(err)
+    }
+    if _, ok := loaded.Get("key1"); ok {
+        t.Fatal("an entry expired while saved was loaded")
+    }
+    if _, ok := loaded.Get("key2"); !ok {
+        t.Fatal("an entry not yet expired was dropped")
+    }
+}
+
+func TestRejected(t *testing.T) {
+    data := export(t, filled(t, "arc"), BytesCodec{})
+
+    flipped := append([]byte(nil), data...)
+    flipped[len(flipped) / 2] ^= 0x40
+
+    bumped := append([]byte(nil), data...)
+    binary.LittleEndian.PutUint32(bumped[len(SnapshotMagic):], SnapshotVersion + 1)
+
+    tests := []struct {
+        name        string
+        data        []byte
+        algorithm   string
+        capacity    int
+        codec       Codec
+        err         error
+    }{
+        {"flipped byte", flipped, "arc", 100, BytesCodec{}, ErrCorrupt},
+        {"truncated payload", data[:len(data) - 10], "arc", 100, BytesCodec{}, ErrCorrupt},
+        {"truncated header", data[:12], "arc", 100, BytesCodec{}, ErrNotSnapshot},
+        {"not a snapshot", []byte("GET / HTTP/1.1\r\n\r\n012345678"), "arc", 100, BytesCodec{}, ErrNotSnapshot},
+        {"bumped version", bumped, "arc", 100, BytesCodec{}, ErrVersion},
+        {"other codec", data, "arc", 100, StringCodec{}, ErrMismatch},
+        {"other algorithm", data, "lru", 100, BytesCodec{}, ErrMismatch},
+        {"other capacity", data, "arc", 50, BytesCodec{}, ErrMismatch},
+    }
+
+    for _, test := range tests {
+        c, err := New(test.algorithm, test.capacity)
+        if err != nil {
+            t.Fatal(err)
+        }
+        c.Set("kept", []byte("kept"))
+
+        path := filepath.Join(t.TempDir(), "cache.snapshot")
+        if err = os.WriteFile(path, test.data, 0644); err != nil {
+            t.Fatal(err)
+        }
+        if err = c.Load(path, test.codec); !errors.Is(err, test.err) {
+            t.Errorf("%v: got %v, expected %v", test.name, err, test.err)
+        }
+
+        // nothing changes when a snapshot is rejected
+        if c.Len() != 1 || !c.Contains("kept") {
+            t.Errorf("%v: the cache changed", test.name)
+        }
+    }
+}
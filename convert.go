@@ -0,0 +1,110 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "github.com/mohammadtauchid/golang-cache/v2/reader"
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// convert rewrites a trace of any supported format in the binary format
+func convert(args []string) {
+    var (
+        flags       *flag.FlagSet = flag.NewFlagSet("convert", flag.ExitOnError)
+        format      string
+        readOptions reader.Options
+        source      string
+        objects     bool
+        tenants     bool
+        header      reader.Header
+        r           reader.Reader
+        w           *reader.Writer
+        trace       simulator.Trace
+        out         *os.File
+        count       int
+        err         error
+    )
+
+    flags.StringVar(&format, "format", "auto", fmt.Sprintf("input trace format, one of %v", reader.Formats))
+    flags.BoolVar(&readOptions.SkipBadLines, "skip-bad-lines", false, "skip trace lines that cannot be parsed")
+    flags.BoolVar(&readOptions.Hex, "hex", false, "trace addresses are hexadecimal")
+    flags.BoolVar(&readOptions.StringKeys, "string-keys", false, "trace addresses are opaque string keys, e.g. URLs")
+    flags.StringVar(&source, "source", "", "description of the trace stored in the header (default: input file name and format)")
+    flags.BoolVar(&objects, "objects", false, "store addresses as object ids, for object-cache traces")
+    flags.BoolVar(&tenants, "tenants", false, "store the tenant of every request (always done for spc and msr traces)")
+    flags.Usage = func() {
+        fmt.Println("Usage: ./main convert [options] [trace file path] [output file path]")
+        fmt.Println("Example: ./main convert resource/Financial resource/Financial.gct")
+        fmt.Println("Options:")
+        flags.PrintDefaults()
+    }
+    flags.Parse(args)
+
+    if flags.NArg() != 2 {
+        flags.Usage()
+        os.Exit(1)
+    }
+
+    if source == "" {
+        source = fmt.Sprintf("%v (%v)", filepath.Base(flags.Arg(0)), format)
+    }
+    header.Source = source
+    // spc and msr requests carry their volume as the tenant
+    switch strings.ToLower(format) {
+    case "spc", "umass", "msr":
+        tenants = true
+    }
+    if objects {
+        header.Flags |= reader.FlagObjectID
+    }
+    if tenants {
+        header.Flags |= reader.FlagTenant
+    }
+
+    r, err = reader.Open(flags.Arg(0), format, readOptions)
+    if err != nil {
+        log.Fatalf("Error reading file: %v", err)
+    }
+    defer r.Close()
+
+    out, err = os.Create(flags.Arg(1))
+    if err != nil {
+        log.Fatalf(err.Error())
+    }
+    defer out.Close()
+
+    w, err = reader.NewWriter(out, header)
+    if err != nil {
+        log.Fatalf(err.Error())
+    }
+
+    for {
+        trace, err = r.Read()
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        if err != nil {
+            log.Fatalf("Error reading file: %v", err)
+        }
+        if err = w.Write(trace); err != nil {
+            log.Fatalf(err.Error())
+        }
+        count++
+    }
+
+    if err = w.Flush(); err != nil {
+        log.Fatalf(err.Error())
+    }
+
+    fmt.Printf("Converted %d records\n", count)
+    if skipped := reader.Skipped(r); skipped > 0 {
+        fmt.Printf("Skipped %d bad lines\n", skipped)
+    }
+}
@@ -0,0 +1,384 @@
+package ensemble
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "strings"
+    "time"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// minShadowSize is the smallest scaled-down shadow cache in which the
+// adaptive policies work; shards whose sampled shadows would be smaller
+// feed every key to full-size shadows instead
+const minShadowSize = 100
+
+type (
+    // Config tunes the ensemble
+    Config struct {
+        Candidates  []string    // algorithms competing for the live cache
+        SampleRate  float64     // fraction of the keys fed to the shadow caches
+        Window      int         // requests per shard between two comparisons
+        Hysteresis  float64     // hit ratio lead needed to switch, as a fraction
+        Patience    int         // consecutive windows the lead must last
+        Shards      int         // independent shards, each choosing its policy
+    }
+
+    // Factory creates a policy of the given algorithm holding size pages
+    Factory func(algorithm string, size int) (simulator.Policy, error)
+
+    // Switch records the live policy of a shard being replaced
+    Switch struct {
+        Request     int
+        Shard       int
+        From        string
+        To          string
+        FromRatio   float64 // shadow hit ratios over the last window
+        ToRatio     float64
+    }
+
+    // shadow is a scaled-down copy of a candidate fed with sampled keys
+    shadow struct {
+        policy      simulator.Policy
+        hit         int // hits and misses of the current window
+        miss        int
+    }
+
+    shard struct {
+        index       int
+        live        simulator.Policy
+        current     int // candidate running live
+        capacity    int
+        shadows     []*shadow
+        threshold   uint64 // keys fed to the shadows hash below it
+        requests    int
+        leader      int // candidate ahead in the previous windows
+        lead        int // consecutive windows it has been ahead
+        served      []int // requests served live by every candidate
+    }
+
+    // Ensemble runs the live cache with one of several candidate policies
+    // and shadow copies of all of them, scaled down to a sample of the keys
+    // as in SHARDS. At the end of every window the shadow hit ratios are
+    // compared, and the live cache of a shard switches to the leading
+    // candidate once it has led by the hysteresis for enough windows. The
+    // new policy is warmed up with the pages of the old one.
+    Ensemble struct {
+        config      Config
+        factory     Factory
+        shards      []*shard
+
+        requests    int
+        hit         int
+        miss        int
+        wc          int
+        evict       int
+        deleted     int
+        switches    []Switch
+        onEvict     func(lba simulator.Key)
+    }
+)
+
+// DefaultConfig returns the configuration used unless told otherwise
+func DefaultConfig() Config {
+    return Config{
+        Candidates: []string{"lru", "arc", "larc"},
+        SampleRate: 0.01,
+        Window:     100000,
+        Hysteresis: 0.01,
+        Patience:   2,
+        Shards:     1,
+    }
+}
+
+func NewEnsemble(config Config, capacity int, factory Factory) (e *Ensemble, err error) {
+    if len(config.Candidates) < 2 {
+        return nil, errors.New("an ensemble needs at least two candidate algorithms")
+    }
+    if config.SampleRate <= 0 || config.SampleRate > 1 {
+        return nil, fmt.Errorf("invalid sample rate %v, expected a fraction in (0, 1]", config.SampleRate)
+    }
+    if config.Shards < 1 || capacity < config.Shards {
+        return nil, fmt.Errorf("cannot split %d pages into %d shards", capacity, config.Shards)
+    }
+    if config.Window < 1 {
+        config.Window = 1
+    }
+
+    e = &Ensemble{
+        config:     config,
+        factory:    factory,
+    }
+
+    for i := 0; i < config.Shards; i++ {
+        s := &shard{
+            index:      i,
+            capacity:   capacity / config.Shards,
+            served:     make([]int, len(config.Candidates)),
+        }
+        if i < capacity % config.Shards {
+            s.capacity++
+        }
+
+        if s.live, err = factory(config.Candidates[0], s.capacity); err != nil {
+            return nil, err
+        }
+
+        // shadows are scaled by the sample rate, or not sampled at all when
+        // too small, so that they see the cache they stand for
+        size := int(float64(s.capacity) * config.SampleRate + 0.5)
+        s.threshold = uint64(config.SampleRate * float64(1 << 32))
+        if size < minShadowSize {
+            size, s.threshold = s.capacity, 1 << 32
+        }
+        for _, algorithm := range config.Candidates {
+            policy, err := factory(algorithm, size)
+            if err != nil {
+                return nil, err
+            }
+            if _, ok := policy.(simulator.Lister); !ok {
+                return nil, fmt.Errorf("algorithm %v cannot be switched from", algorithm)
+            }
+            s.shadows = append(s.shadows, &shadow{policy: policy})
+        }
+
+        e.shards = append(e.shards, s)
+    }
+
+    return e, nil
+}
+
+// hash spreads keys evenly, so that shards and samples are unbiased
+func hash(key simulator.Key) uint64 {
+    h := (key.Lo ^ key.Hi * 0x9e3779b97f4a7c15) * 0xff51afd7ed558ccd
+    return h ^ h >> 32
+}
+
+func (e *Ensemble) Get(trace simulator.Trace) (err error) {
+    h := hash(trace.Key)
+    s := e.shard(trace.Key)
+
+    before := s.live.Stats()
+    if err = s.live.Get(trace); err != nil {
+        return err
+    }
+    after := s.live.Stats()
+
+    e.hit += after.Hit - before.Hit
+    e.miss += after.Miss - before.Miss
+    e.wc += after.Write - before.Write
+    e.evict += after.Eviction - before.Eviction
+    e.deleted += after.Delete - before.Delete
+
+    if trace.Op == simulator.OpDelete {
+        for _, sh := range s.shadows {
+            sh.policy.Remove(trace.Key)
+        }
+        return nil
+    }
+
+    e.requests++
+    s.requests++
+    s.served[s.current]++
+
+    if h & 0xffffffff < s.threshold {
+        for _, sh := range s.shadows {
+            before := sh.policy.Stats()
+            if err = sh.policy.Get(trace); err != nil {
+                return err
+            }
+            if sh.policy.Stats().Hit > before.Hit {
+                sh.hit++
+            } else {
+                sh.miss++
+            }
+        }
+    }
+
+    if s.requests % e.config.Window == 0 {
+        return e.compare(s)
+    }
+
+    return nil
+}
+
+// compare closes the window of a shard, switching its live policy when a
+// candidate has led long enough
+func (e *Ensemble) compare(s *shard) (err error) {
+    var ratios []float64 = make([]float64, len(s.shadows))
+
+    best := s.current
+    for i, sh := range s.shadows {
+        if sh.hit + sh.miss > 0 {
+            ratios[i] = float64(sh.hit) / float64(sh.hit + sh.miss)
+        }
+        if ratios[i] > ratios[best] {
+            best = i
+        }
+        sh.hit, sh.miss = 0, 0
+    }
+
+    if best == s.current || ratios[best] < ratios[s.current] + e.config.Hysteresis {
+        s.lead = 0
+        return nil
+    }
+
+    if best == s.leader {
+        s.lead++
+    } else {
+        s.leader, s.lead = best, 1
+    }
+    if s.lead < e.config.Patience {
+        return nil
+    }
+
+    live, err := e.factory(e.config.Candidates[best], s.capacity)
+    if err != nil {
+        return err
+    }
+    keys := s.live.(simulator.Lister).Keys()
+    for _, key := range keys {
+        live.Get(simulator.Trace{Key: key, Op: simulator.OpRead})
+        // admission filters such as larc's only take a page seen before
+        if !live.Contains(key) {
+            live.Get(simulator.Trace{Key: key, Op: simulator.OpRead})
+        }
+    }
+
+    // pages the new policy did not keep are evicted by the switch
+    for _, key := range keys {
+        if live.Contains(key) {
+            continue
+        }
+        e.evict++
+        if e.onEvict != nil {
+            e.onEvict(key)
+        }
+    }
+    if e.onEvict != nil {
+        live.OnEvict(e.onEvict)
+    }
+
+    e.switches = append(e.switches, Switch{
+        Request:    e.requests,
+        Shard:      s.index,
+        From:       e.config.Candidates[s.current],
+        To:         e.config.Candidates[best],
+        FromRatio:  ratios[s.current],
+        ToRatio:    ratios[best],
+    })
+
+    s.live = live
+    s.current = best
+    s.lead = 0
+
+    return nil
+}
+
+func (e *Ensemble) shard(lba simulator.Key) *shard {
+    return e.shards[(hash(lba) >> 32) % uint64(len(e.shards))]
+}
+
+// Contains reports whether lba is cached by the live policy of its shard
+func (e *Ensemble) Contains(lba simulator.Key) bool {
+    return e.shard(lba).live.Contains(lba)
+}
+
+// Remove drops lba from the live and shadow policies of its shard,
+// returning whether it was cached live
+func (e *Ensemble) Remove(lba simulator.Key) (removed bool) {
+    s := e.shard(lba)
+    for _, sh := range s.shadows {
+        sh.policy.Remove(lba)
+    }
+    return s.live.Remove(lba)
+}
+
+// OnEvict registers fn to be called with every page evicted live
+func (e *Ensemble) OnEvict(fn func(lba simulator.Key)) {
+    e.onEvict = fn
+    for _, s := range e.shards {
+        s.live.OnEvict(fn)
+    }
+}
+
+// Switches returns the switch events so far
+func (e *Ensemble) Switches() []Switch {
+    return e.switches
+}
+
+func (e *Ensemble) Stats() (stats simulator.Stats) {
+    stats = simulator.Stats{
+        Hit:        e.hit,
+        Miss:       e.miss,
+        Write:      e.wc,
+        Eviction:   e.evict,
+        Delete:     e.deleted,
+    }
+
+    for _, s := range e.shards {
+        stats.Occupancy += s.live.Stats().Occupancy
+        stats.Capacity += s.capacity
+    }
+
+    return stats
+}
+
+// SetPurgeGhosts forwards the option to the live and shadow policies
+// keeping ghost lists; policies created by later switches keep the default
+func (e *Ensemble) SetPurgeGhosts(purge bool) {
+    for _, s := range e.shards {
+        policies := []simulator.Policy{s.live}
+        for _, sh := range s.shadows {
+            policies = append(policies, sh.policy)
+        }
+        for _, policy := range policies {
+            if purger, ok := policy.(interface{ SetPurgeGhosts(bool) }); ok {
+                purger.SetPurgeGhosts(purge)
+            }
+        }
+    }
+}
+
+func (e *Ensemble) PrintToFile(file io.Writer, elapsed time.Duration) (err error) {
+    stats := e.Stats()
+
+    fmt.Fprintf(file, "cache size: %d\n", stats.Capacity)
+    fmt.Fprintf(file, "candidates: %v\n", strings.Join(e.config.Candidates, ", "))
+    fmt.Fprintf(file, "cache hit: %d\n", e.hit)
+    fmt.Fprintf(file, "cache miss: %d\n", e.miss)
+    fmt.Fprintf(file, "cache hit ratio: %.4f%%\n", float64(e.hit) / float64(e.hit + e.miss) * 100)
+    fmt.Fprintf(file, "write count: %d\n", e.wc)
+    fmt.Fprintf(file, "delete count: %d\n", e.deleted)
+    fmt.Fprintf(file, "switch count: %d\n", len(e.switches))
+
+    for i, algorithm := range e.config.Candidates {
+        served := 0
+        for _, s := range e.shards {
+            served += s.served[i]
+        }
+        fmt.Fprintf(file, "served by %v: %d (%.4f%%)\n", algorithm, served, float64(served) / float64(e.requests) * 100)
+    }
+
+    for i, s := range e.shards {
+        fmt.Fprintf(file, "shard %d final policy: %v\n", i, e.config.Candidates[s.current])
+    }
+    fmt.Fprintf(file, "time execution: %8.4f\n", elapsed.Seconds())
+
+    return nil
+}
+
+// PrintTelemetryToFile writes the switch events
+func (e *Ensemble) PrintTelemetryToFile(file io.Writer) (err error) {
+    io.WriteString(file, "request,shard,from,to,from hit ratio,to hit ratio\n")
+    for _, sw := range e.switches {
+        fmt.Fprintf(
+            file, "%d,%d,%s,%s,%.4f,%.4f\n",
+            sw.Request, sw.Shard, sw.From, sw.To, sw.FromRatio, sw.ToRatio,
+        )
+    }
+
+    return nil
+}
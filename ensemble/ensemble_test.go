@@ -0,0 +1,304 @@
+package ensemble
+
+import (
+    "io"
+    "testing"
+    "time"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// scripted is a policy whose hits are decided by a function of the key,
+// so that every candidate has a known hit ratio on a workload; requested
+// pages are cached when admit allows it and evicted first in, first out
+type scripted struct {
+    capacity    int
+    hits        func(key uint64) bool
+    admit       func(key uint64) bool
+    keys        []simulator.Key
+    cached      map[simulator.Key]bool
+    hit         int
+    miss        int
+    evict       int
+    onEvict     func(lba simulator.Key)
+}
+
+func (p *scripted) Get(trace simulator.Trace) error {
+    if trace.Op == simulator.OpDelete {
+        p.Remove(trace.Key)
+        return nil
+    }
+    if p.cached[trace.Key] || p.hits(trace.Key.Lo) {
+        p.hit++
+    } else {
+        p.miss++
+    }
+
+    if p.cached[trace.Key] || (p.admit != nil && !p.admit(trace.Key.Lo)) {
+        return nil
+    }
+    if len(p.keys) == p.capacity {
+        evicted := p.keys[0]
+        p.keys = p.keys[1:]
+        delete(p.cached, evicted)
+        p.evict++
+        if p.onEvict != nil {
+            p.onEvict(evicted)
+        }
+    }
+    p.keys = append(p.keys, trace.Key)
+    p.cached[trace.Key] = true
+    return nil
+}
+
+func (p *scripted) Contains(key simulator.Key) bool {
+    return p.cached[key]
+}
+
+func (p *scripted) Remove(key simulator.Key) bool {
+    if !p.cached[key] {
+        return false
+    }
+    delete(p.cached, key)
+    for i := range p.keys {
+        if p.keys[i] == key {
+            p.keys = append(p.keys[:i], p.keys[i + 1:]...)
+            break
+        }
+    }
+    return true
+}
+
+func (p *scripted) OnEvict(fn func(lba simulator.Key)) {
+    p.onEvict = fn
+}
+
+func (p *scripted) Keys() []simulator.Key {
+    return append([]simulator.Key(nil), p.keys...)
+}
+
+func (p *scripted) Stats() simulator.Stats {
+    return simulator.Stats{
+        Hit:        p.hit,
+        Miss:       p.miss,
+        Eviction:   p.evict,
+        Occupancy:  len(p.keys),
+        Capacity:   p.capacity,
+    }
+}
+
+func (p *scripted) PrintToFile(file io.Writer, elapsed time.Duration) error {
+    return nil
+}
+
+// factory creates scripted policies hitting keys as hits[algorithm] says
+func factory(hits map[string]func(key uint64) bool, admit map[string]func(key uint64) bool) Factory {
+    return func(algorithm string, size int) (simulator.Policy, error) {
+        return &scripted{
+            capacity:   size,
+            hits:       hits[algorithm],
+            admit:      admit[algorithm],
+            cached:     make(map[simulator.Key]bool),
+        }, nil
+    }
+}
+
+// ratio hits a fraction of the keys, by their last two digits
+func ratio(percent uint64) func(key uint64) bool {
+    return func(key uint64) bool { return key % 100 < percent }
+}
+
+// phases hits keys below 1e6 as first does and the others as then does,
+// for a workload whose best policy changes halfway through
+func phases(first, then uint64) func(key uint64) bool {
+    return func(key uint64) bool {
+        if key < 1e6 {
+            return key % 100 < first
+        }
+        return key % 100 < then
+    }
+}
+
+// run requests n distinct keys from start
+func run(t *testing.T, e *Ensemble, start, n int) {
+    for i := start; i < start + n; i++ {
+        if err := e.Get(simulator.Trace{Key: simulator.IntKey(uint64(i)), Op: simulator.OpRead}); err != nil {
+            t.Fatal(err)
+        }
+    }
+}
+
+func config(window, patience int, hysteresis float64) Config {
+    return Config{
+        Candidates: []string{"lru", "arc"},
+        SampleRate: 0.01,
+        Window:     window,
+        Hysteresis: hysteresis,
+        Patience:   patience,
+        Shards:     1,
+    }
+}
+
+// TestSampling checks that large shadows are scaled down by the sample rate
+// and fed that fraction of the keys, always the same keys, while small ones
+// stand for the whole cache
+func TestSampling(t *testing.T) {
+    hits := map[string]func(uint64) bool{"lru": ratio(0), "arc": ratio(0)}
+
+    e, err := NewEnsemble(config(1e9, 1, 0), 100000, factory(hits, nil))
+    if err != nil {
+        t.Fatal(err)
+    }
+    run(t, e, 0, 100000)
+    first := e.shards[0].shadows[0].policy.Stats().Miss
+    run(t, e, 0, 100000)
+
+    for i, sh := range e.shards[0].shadows {
+        stats := sh.policy.Stats()
+        if stats.Capacity != 1000 {
+            t.Errorf("shadow %d holds %d pages, expected 1000", i, stats.Capacity)
+        }
+        if first < 800 || first > 1200 {
+            t.Errorf("shadow %d saw %d of 100000 keys, expected about 1000", i, first)
+        }
+        if stats.Hit + stats.Miss != 2 * first {
+            t.Errorf("shadow %d saw %d requests of the same keys twice, expected %d", i, stats.Hit + stats.Miss, 2 * first)
+        }
+    }
+
+    e, err = NewEnsemble(config(1e9, 1, 0), 5000, factory(hits, nil))
+    if err != nil {
+        t.Fatal(err)
+    }
+    run(t, e, 0, 1000)
+
+    for i, sh := range e.shards[0].shadows {
+        stats := sh.policy.Stats()
+        if stats.Capacity != 5000 || stats.Hit + stats.Miss != 1000 {
+            t.Errorf("shadow %d: %d requests in %d pages, expected all 1000 in 5000", i, stats.Hit + stats.Miss, stats.Capacity)
+        }
+    }
+}
+
+// TestHysteresis checks that a candidate ahead by less than the hysteresis
+// never replaces the live policy
+func TestHysteresis(t *testing.T) {
+    hits := map[string]func(uint64) bool{"lru": ratio(50), "arc": ratio(55)}
+
+    e, err := NewEnsemble(config(1000, 1, 0.1), 1000, factory(hits, nil))
+    if err != nil {
+        t.Fatal(err)
+    }
+    run(t, e, 0, 20000)
+    if len(e.Switches()) != 0 {
+        t.Errorf("switched %d times within the hysteresis: %+v", len(e.Switches()), e.Switches())
+    }
+
+    e, err = NewEnsemble(config(1000, 1, 0.01), 1000, factory(hits, nil))
+    if err != nil {
+        t.Fatal(err)
+    }
+    run(t, e, 0, 20000)
+    if len(e.Switches()) != 1 {
+        t.Errorf("switched %d times past the hysteresis, expected once", len(e.Switches()))
+    }
+}
+
+// TestPatience checks that the live policy is replaced only once a candidate
+// has led for the patience, in consecutive windows
+func TestPatience(t *testing.T) {
+    hits := map[string]func(uint64) bool{"lru": ratio(40), "arc": ratio(60)}
+
+    e, err := NewEnsemble(config(1000, 3, 0.01), 1000, factory(hits, nil))
+    if err != nil {
+        t.Fatal(err)
+    }
+    run(t, e, 0, 2999)
+    if len(e.Switches()) != 0 {
+        t.Fatalf("switched before the patience: %+v", e.Switches())
+    }
+    run(t, e, 2999, 1)
+    switches := e.Switches()
+    if len(switches) != 1 || switches[0].Request != 3000 || switches[0].From != "lru" || switches[0].To != "arc" {
+        t.Fatalf("got %+v, expected a switch from lru to arc after 3000 requests", switches)
+    }
+    if switches[0].FromRatio != 0.4 || switches[0].ToRatio != 0.6 {
+        t.Errorf("switched at hit ratios %v and %v, expected 0.4 and 0.6", switches[0].FromRatio, switches[0].ToRatio)
+    }
+
+    // a lead broken by a window behind starts over
+    e.shards[0].shadows[0].policy.(*scripted).hits = ratio(60)
+    e.shards[0].shadows[1].policy.(*scripted).hits = func(key uint64) bool { return key / 1000 % 3 == 1 || key % 100 < 40 }
+    run(t, e, 3000, 30000)
+    if len(e.Switches()) != 1 {
+        t.Errorf("switched back on a lead interrupted every third window: %+v", e.Switches()[1:])
+    }
+}
+
+// TestWorkloadChange runs a workload whose best policy changes halfway
+// through, expecting exactly one switch, a patience after the change
+func TestWorkloadChange(t *testing.T) {
+    hits := map[string]func(uint64) bool{"lru": phases(70, 30), "arc": phases(30, 70)}
+
+    e, err := NewEnsemble(config(1000, 2, 0.05), 1000, factory(hits, nil))
+    if err != nil {
+        t.Fatal(err)
+    }
+    run(t, e, 0, 10000)
+    run(t, e, 1e6, 10000)
+
+    switches := e.Switches()
+    if len(switches) != 1 {
+        t.Fatalf("switched %d times, expected once: %+v", len(switches), switches)
+    }
+    if switches[0].Request != 12000 || switches[0].To != "arc" {
+        t.Errorf("got %+v, expected a switch to arc after 12000 requests", switches[0])
+    }
+    if e.shards[0].served[0] != 12000 || e.shards[0].served[1] != 8000 {
+        t.Errorf("lru served %d and arc %d requests, expected 12000 and 8000", e.shards[0].served[0], e.shards[0].served[1])
+    }
+}
+
+// TestSwitchEvictions checks that pages the new policy does not take from
+// the old one count as evictions and are reported
+func TestSwitchEvictions(t *testing.T) {
+    hits := map[string]func(uint64) bool{"lru": ratio(0), "arc": ratio(50)}
+    admit := map[string]func(uint64) bool{"arc": func(key uint64) bool { return key % 2 == 0 }}
+
+    e, err := NewEnsemble(config(1000, 1, 0.01), 500, factory(hits, admit))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var reported []simulator.Key
+    e.OnEvict(func(key simulator.Key) { reported = append(reported, key) })
+
+    run(t, e, 0, 999)
+    if evicted := e.Stats().Eviction; evicted != 499 || len(reported) != 499 {
+        t.Fatalf("%d evictions and %d reported before the switch, expected 499", evicted, len(reported))
+    }
+    live := e.shards[0].live.(*scripted).Keys()
+
+    run(t, e, 999, 1)
+    if len(e.Switches()) != 1 {
+        t.Fatalf("switched %d times, expected once", len(e.Switches()))
+    }
+
+    // the odd half of the 500 pages cached live is refused by arc
+    if evicted := e.Stats().Eviction; evicted != 499 + 1 + 250 {
+        t.Errorf("%d evictions, expected 750", evicted)
+    }
+    dropped := reported[500:]
+    if len(dropped) != 250 {
+        t.Fatalf("%d pages reported dropped by the switch, expected 250", len(dropped))
+    }
+    for _, key := range dropped {
+        if key.Lo % 2 == 0 || e.Contains(key) {
+            t.Errorf("page %v reported dropped", key)
+        }
+    }
+    for _, key := range live {
+        if key.Lo % 2 == 0 && !e.Contains(key) {
+            t.Errorf("page %v lost by the switch", key)
+        }
+    }
+}
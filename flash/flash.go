@@ -0,0 +1,158 @@
+package flash
+
+import (
+    "fmt"
+    "io"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+const secondsPerDay = 24 * 60 * 60
+
+type (
+    // Device describes the flash device a cache is stored on
+    Device struct {
+        PageSize            int     // bytes written per cached page, when the trace has no sizes
+        BlockSize           int     // bytes of an erase block
+        TBW                 float64 // rated endurance, in terabytes written
+        WriteAmplification  float64 // device-internal write amplification, 1 when unknown
+        Duration            float64 // seconds covered by the trace, overrides its timestamps
+    }
+
+    // Wear accumulates the flash writes of a cache over a run. It observes
+    // the write counter of the cache: every page written costs the size of
+    // the request, or a page when the trace has no sizes.
+    Wear struct {
+        device      Device
+
+        requests    int
+        writes      int
+        bytes       int64   // bytes written into the cache
+        hostBytes   int64   // bytes of write requests
+        startTime   float64
+        endTime     float64
+    }
+)
+
+func NewWear(device Device) *Wear {
+    if device.WriteAmplification <= 0 {
+        device.WriteAmplification = 1
+    }
+
+    return &Wear{
+        device:     device,
+        requests:   0,
+        writes:     0,
+        bytes:      0,
+        hostBytes:  0,
+    }
+}
+
+func (w *Wear) size(trace simulator.Trace) int64 {
+    if trace.Size > 0 {
+        return int64(trace.Size)
+    }
+    return int64(w.device.PageSize)
+}
+
+func (w *Wear) Observe(trace simulator.Trace, before simulator.Stats, after simulator.Stats) {
+    w.observe(trace, before, after, trace.Op == simulator.OpWrite)
+}
+
+// ObserveDemotion counts a page demoted into the cache by the tier above
+// as a write of the device only: the host did not request it
+func (w *Wear) ObserveDemotion(trace simulator.Trace, before simulator.Stats, after simulator.Stats) {
+    w.observe(trace, before, after, false)
+}
+
+// observe counts the writes of a request into the cache; host tells a
+// write request of the host
+func (w *Wear) observe(trace simulator.Trace, before simulator.Stats, after simulator.Stats, host bool) {
+    if w.requests == 0 {
+        w.startTime = trace.Timestamp
+    }
+    w.endTime = trace.Timestamp
+    w.requests++
+
+    if host {
+        w.hostBytes += w.size(trace)
+    }
+
+    if written := after.Write - before.Write; written > 0 {
+        w.writes += written
+        w.bytes += int64(written) * w.size(trace)
+    }
+}
+
+// Bytes returns the bytes written into the cache
+func (w *Wear) Bytes() int64 {
+    return w.bytes
+}
+
+// DeviceBytes returns the bytes written to the flash cells, after the
+// device-internal write amplification
+func (w *Wear) DeviceBytes() float64 {
+    return float64(w.bytes) * w.device.WriteAmplification
+}
+
+// Erases estimates the number of block erases caused by the writes
+func (w *Wear) Erases() float64 {
+    if w.device.BlockSize <= 0 {
+        return 0
+    }
+    return w.DeviceBytes() / float64(w.device.BlockSize)
+}
+
+// Duration returns the seconds covered by the run
+func (w *Wear) Duration() float64 {
+    if w.device.Duration > 0 {
+        return w.device.Duration
+    }
+    return w.endTime - w.startTime
+}
+
+// Lifetime estimates in days how long the device lasts at the write rate of
+// the run; ok is false without a rating or a duration
+func (w *Wear) Lifetime() (days float64, ok bool) {
+    if w.device.TBW <= 0 || w.Duration() <= 0 || w.bytes == 0 {
+        return 0, false
+    }
+
+    perDay := w.DeviceBytes() / w.Duration() * secondsPerDay
+    return w.device.TBW * 1e12 / perDay, true
+}
+
+// PrintToFile reports the wear of a cache holding capacity pages
+func (w *Wear) PrintToFile(file io.Writer, capacity int) (err error) {
+    fmt.Fprintf(file, "flash write bytes: %d\n", w.bytes)
+    fmt.Fprintf(file, "flash writes per request: %.4f\n", ratio(float64(w.writes), float64(w.requests)))
+    if w.hostBytes > 0 {
+        fmt.Fprintf(file, "flash write amplification: %.4f (of host write bytes)\n", ratio(float64(w.bytes), float64(w.hostBytes)))
+    }
+
+    if w.device.BlockSize > 0 {
+        fmt.Fprintf(file, "flash block erases: %.0f\n", w.Erases())
+
+        blocks := float64(capacity) * float64(w.device.PageSize) / float64(w.device.BlockSize)
+        if blocks >= 1 {
+            fmt.Fprintf(file, "flash erase cycles per block: %.4f\n", w.Erases() / blocks)
+        }
+    }
+
+    if w.bytes == 0 {
+        io.WriteString(file, "flash lifetime: unlimited, nothing was written\n")
+    } else if days, ok := w.Lifetime(); ok {
+        fmt.Fprintf(file, "flash lifetime: %.2f days (%.0f TBW over %.2f s of trace)\n", days, w.device.TBW, w.Duration())
+    } else if w.device.TBW > 0 {
+        io.WriteString(file, "flash lifetime: unknown, the trace has no duration\n")
+    }
+
+    return nil
+}
+
+func ratio(part float64, total float64) float64 {
+    if total == 0 {
+        return 0
+    }
+    return part / total
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "strings"
+
+    "github.com/mohammadtauchid/golang-cache/v2/generator"
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// generate writes a synthetic trace in the "addr,op" format
+func generate(args []string) {
+    var (
+        flags       *flag.FlagSet = flag.NewFlagSet("generate", flag.ExitOnError)
+        options     generator.Options
+        workload    string
+        requests    int
+        writeRatio  float64
+        seed        int64
+        dist        generator.Distribution
+        gen         *generator.Generator
+        err         error
+    )
+
+    flags.StringVar(&workload, "workload", "zipf", fmt.Sprintf(
+        "workload spec: one of %v, mixed with \"+\" and optional \"*weight\", phases separated by \",\" with \":requests\"",
+        strings.Join(generator.Workloads, ", "),
+    ))
+    flags.IntVar(&requests, "requests", 1000000, "number of requests")
+    flags.IntVar(&options.Addresses, "addresses", 100000, "size of the address space")
+    flags.Float64Var(&options.Alpha, "alpha", 0.9, "zipf skew")
+    flags.Float64Var(&options.HotFraction, "hot-fraction", 0.1, "share of the address space that is hot")
+    flags.Float64Var(&options.HotProbability, "hot-probability", 0.9, "share of the requests sent to the hot set")
+    flags.Float64Var(&writeRatio, "write-ratio", 0.3, "share of the requests that are writes")
+    flags.Int64Var(&seed, "seed", 1, "random seed")
+    flags.Usage = func() {
+        fmt.Println("Usage: ./main generate [options] [output file path]")
+        fmt.Println("Example: ./main generate -workload \"zipf:500000,zipf*0.5+scan*0.5:100000\" resource/synthetic")
+        fmt.Println("Options:")
+        flags.PrintDefaults()
+    }
+    flags.Parse(args)
+
+    if flags.NArg() != 1 {
+        flags.Usage()
+        os.Exit(1)
+    }
+
+    dist, err = generator.Parse(workload, options)
+    if err != nil {
+        fmt.Println(err.Error())
+        os.Exit(1)
+    }
+    gen = generator.New(dist, requests, writeRatio, seed)
+
+    if err = writeTrace(flags.Arg(0), gen); err != nil {
+        log.Fatalf(err.Error())
+    }
+}
+
+// writeTrace writes the requests of gen to path, one "addr,op" per line
+func writeTrace(path string, gen *generator.Generator) (err error) {
+    var (
+        trace   simulator.Trace
+        out     *os.File
+        w       *bufio.Writer
+    )
+
+    if out, err = os.Create(path); err != nil {
+        return err
+    }
+    defer func() {
+        if closeErr := out.Close(); err == nil {
+            err = closeErr
+        }
+    }()
+
+    w = bufio.NewWriter(out)
+    for {
+        trace, err = gen.Read()
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        if err != nil {
+            return err
+        }
+        if _, err = fmt.Fprintf(w, "%v,%v\n", trace.Key, trace.Op); err != nil {
+            return err
+        }
+    }
+
+    return w.Flush()
+}
@@ -0,0 +1,155 @@
+package generator
+
+import (
+    "math"
+    "math/rand"
+    "sort"
+)
+
+type (
+    // Distribution picks the address of the next request of a workload
+    Distribution interface {
+        Next(r *rand.Rand) int
+    }
+
+    // Uniform draws addresses uniformly from [0, N)
+    Uniform struct {
+        N           int
+    }
+
+    // Zipf draws addresses from [0, N) with the probability of address i
+    // proportional to 1 / (i + 1)^Alpha
+    Zipf struct {
+        N           int
+        Alpha       float64
+        cdf         []float64
+    }
+
+    // HotSet sends Probability of the requests to the first Hot addresses
+    // and the rest uniformly to the remaining ones of [0, N)
+    HotSet struct {
+        N           int
+        Hot         int
+        Probability float64
+    }
+
+    // Scan walks sequentially through addresses never seen before,
+    // starting at Start
+    Scan struct {
+        Start       int
+        position    int
+    }
+
+    // Loop walks sequentially through [Start, Start + N) over and over
+    Loop struct {
+        Start       int
+        N           int
+        position    int
+    }
+
+    // Mixture draws every request from one of its distributions, chosen
+    // with probability proportional to its weight
+    Mixture struct {
+        Distributions   []Distribution
+        Weights         []float64
+    }
+
+    // Phase runs a distribution for a number of requests
+    Phase struct {
+        Distribution    Distribution
+        Requests        int
+    }
+
+    // Phases runs its phases one after the other, starting over after the
+    // last one
+    Phases struct {
+        Phases      []Phase
+        current     int
+        served      int
+    }
+)
+
+func (u *Uniform) Next(r *rand.Rand) int {
+    return r.Intn(u.N)
+}
+
+func NewZipf(n int, alpha float64) *Zipf {
+    var (
+        cdf []float64 = make([]float64, n)
+        sum float64
+    )
+
+    for i := 0; i < n; i++ {
+        sum += 1 / math.Pow(float64(i + 1), alpha)
+        cdf[i] = sum
+    }
+    for i := range cdf {
+        cdf[i] /= sum
+    }
+
+    return &Zipf{
+        N:      n,
+        Alpha:  alpha,
+        cdf:    cdf,
+    }
+}
+
+func (z *Zipf) Next(r *rand.Rand) int {
+    if z.cdf == nil {
+        *z = *NewZipf(z.N, z.Alpha)
+    }
+
+    u := r.Float64()
+    index := sort.SearchFloat64s(z.cdf, u)
+    if index >= z.N {
+        index = z.N - 1
+    }
+    return index
+}
+
+func (h *HotSet) Next(r *rand.Rand) int {
+    if h.Hot <= 0 || h.Hot >= h.N {
+        return r.Intn(h.N)
+    }
+    if r.Float64() < h.Probability {
+        return r.Intn(h.Hot)
+    }
+    return h.Hot + r.Intn(h.N - h.Hot)
+}
+
+func (s *Scan) Next(r *rand.Rand) int {
+    s.position++
+    return s.Start + s.position - 1
+}
+
+func (l *Loop) Next(r *rand.Rand) int {
+    address := l.Start + l.position
+    l.position = (l.position + 1) % l.N
+    return address
+}
+
+func (m *Mixture) Next(r *rand.Rand) int {
+    var total float64
+
+    for _, weight := range m.Weights {
+        total += weight
+    }
+
+    u := r.Float64() * total
+    for i, weight := range m.Weights {
+        if u < weight {
+            return m.Distributions[i].Next(r)
+        }
+        u -= weight
+    }
+    return m.Distributions[len(m.Distributions) - 1].Next(r)
+}
+
+func (p *Phases) Next(r *rand.Rand) int {
+    if p.served >= p.Phases[p.current].Requests {
+        p.current = (p.current + 1) % len(p.Phases)
+        p.served = 0
+    }
+    p.served++
+    return p.Phases[p.current].Distribution.Next(r)
+}
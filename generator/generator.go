@@ -0,0 +1,157 @@
+package generator
+
+import (
+    "fmt"
+    "io"
+    "math/rand"
+    "strconv"
+    "strings"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+type (
+    // Generator produces a reproducible synthetic trace; it satisfies
+    // reader.Reader so it can be used wherever a trace file is read
+    Generator struct {
+        distribution    Distribution
+        requests        int
+        writeRatio      float64
+        rand            *rand.Rand
+        count           int
+    }
+
+    // Options parameterise the distributions named in a workload spec
+    Options struct {
+        Addresses       int     // size of the address space
+        Alpha           float64 // zipf skew
+        HotFraction     float64 // share of the address space that is hot
+        HotProbability  float64 // share of the requests sent to the hot set
+    }
+)
+
+// Workloads lists the distribution names understood by Parse
+var Workloads = []string{"zipf", "uniform", "hotset", "scan", "loop"}
+
+// New creates a generator of the given number of requests drawn from
+// distribution, a writeRatio share of them being writes
+func New(distribution Distribution, requests int, writeRatio float64, seed int64) *Generator {
+    return &Generator{
+        distribution:   distribution,
+        requests:       requests,
+        writeRatio:     writeRatio,
+        rand:           rand.New(rand.NewSource(seed)),
+        count:          0,
+    }
+}
+
+func (g *Generator) Read() (trace simulator.Trace, err error) {
+    if g.count >= g.requests {
+        return trace, io.EOF
+    }
+    g.count++
+
+    trace.Key = simulator.IntKey(uint64(g.distribution.Next(g.rand)))
+    trace.Op = simulator.OpRead
+    if g.rand.Float64() < g.writeRatio {
+        trace.Op = simulator.OpWrite
+    }
+
+    return trace, nil
+}
+
+func (g *Generator) Close() error {
+    return nil
+}
+
+// Parse builds a distribution from a workload spec. Phases are separated by
+// commas and run for the given number of requests, e.g.
+// "zipf:100000,scan:20000"; within a phase, distributions joined by "+" are
+// mixed with optional weights, e.g. "zipf*0.9+scan*0.1". Scans start past
+// the address space and continue each other, so they never revisit an
+// address.
+func Parse(spec string, options Options) (distribution Distribution, err error) {
+    var (
+        phases      []Phase
+        parts       []string = strings.Split(spec, ",")
+        scan        *Scan    = &Scan{Start: options.Addresses}
+    )
+
+    for _, part := range parts {
+        var phase Phase
+
+        mixture, requests, found := strings.Cut(part, ":")
+        if found {
+            phase.Requests, err = strconv.Atoi(requests)
+            if err != nil || phase.Requests <= 0 {
+                return nil, fmt.Errorf("invalid phase length %q", requests)
+            }
+        } else if len(parts) > 1 {
+            return nil, fmt.Errorf("phase %q needs a length, e.g. %v:10000", part, part)
+        }
+
+        phase.Distribution, err = parseMixture(mixture, options, scan)
+        if err != nil {
+            return nil, err
+        }
+        phases = append(phases, phase)
+    }
+
+    if len(phases) == 1 {
+        return phases[0].Distribution, nil
+    }
+    return &Phases{Phases: phases}, nil
+}
+
+func parseMixture(spec string, options Options, scan *Scan) (distribution Distribution, err error) {
+    var mixture Mixture
+
+    for _, component := range strings.Split(spec, "+") {
+        name, weight, found := strings.Cut(component, "*")
+
+        value := 1.0
+        if found {
+            value, err = strconv.ParseFloat(weight, 64)
+            if err != nil || value < 0 {
+                return nil, fmt.Errorf("invalid weight %q", weight)
+            }
+        }
+
+        distribution, err = newDistribution(strings.TrimSpace(name), options, scan)
+        if err != nil {
+            return nil, err
+        }
+        mixture.Distributions = append(mixture.Distributions, distribution)
+        mixture.Weights = append(mixture.Weights, value)
+    }
+
+    if len(mixture.Distributions) == 1 {
+        return mixture.Distributions[0], nil
+    }
+    return &mixture, nil
+}
+
+func newDistribution(name string, options Options, scan *Scan) (Distribution, error) {
+    if options.Addresses <= 0 {
+        return nil, fmt.Errorf("address space must be positive")
+    }
+
+    switch strings.ToLower(name) {
+    case "zipf":
+        return NewZipf(options.Addresses, options.Alpha), nil
+    case "uniform":
+        return &Uniform{N: options.Addresses}, nil
+    case "hotset":
+        return &HotSet{
+            N:              options.Addresses,
+            Hot:            int(options.HotFraction * float64(options.Addresses)),
+            Probability:    options.HotProbability,
+        }, nil
+    case "scan":
+        return scan, nil
+    case "loop":
+        return &Loop{N: options.Addresses}, nil
+    default:
+        return nil, fmt.Errorf("workload %v not supported, use one of %v", name, Workloads)
+    }
+}
@@ -0,0 +1,120 @@
+package generator
+
+import (
+    "errors"
+    "io"
+    "testing"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+var options = Options{
+    Addresses:      1000,
+    Alpha:          0.9,
+    HotFraction:    0.1,
+    HotProbability: 0.9,
+}
+
+// generate reads every request of a workload
+func generate(t *testing.T, spec string, requests int, seed int64) (traces []simulator.Trace) {
+    distribution, err := Parse(spec, options)
+    if err != nil {
+        t.Fatalf("%v: %v", spec, err)
+    }
+
+    g := New(distribution, requests, 0.3, seed)
+    for {
+        trace, err := g.Read()
+        if errors.Is(err, io.EOF) {
+            return traces
+        }
+        if err != nil {
+            t.Fatalf("%v: %v", spec, err)
+        }
+        traces = append(traces, trace)
+    }
+}
+
+// TestSeed checks that a seed reproduces its trace, and another seed does not
+func TestSeed(t *testing.T) {
+    spec := "zipf:5000,zipf*0.5+scan*0.5:2000,hotset:3000"
+
+    first, again, other := generate(t, spec, 20000, 1), generate(t, spec, 20000, 1), generate(t, spec, 20000, 2)
+    same := 0
+    for i := range first {
+        if first[i] != again[i] {
+            t.Fatalf("request %d: %+v, then %+v with the same seed", i, first[i], again[i])
+        }
+        if first[i] == other[i] {
+            same++
+        }
+    }
+    if same == len(first) {
+        t.Error("seeds 1 and 2 generated the same trace")
+    }
+}
+
+// TestBounds checks that every workload generates the requested number of
+// requests, within its addresses
+func TestBounds(t *testing.T) {
+    tests := []struct {
+        spec        string
+        low         uint64
+        high        uint64 // past the last address
+    }{
+        {"zipf", 0, 1000},
+        {"uniform", 0, 1000},
+        {"hotset", 0, 1000},
+        {"loop", 0, 1000},
+        {"scan", 1000, 1000 + 5000},
+        {"zipf*0.9+scan*0.1", 0, 1000 + 5000},
+    }
+
+    for _, test := range tests {
+        traces := generate(t, test.spec, 5000, 1)
+        if len(traces) != 5000 {
+            t.Errorf("%v: %d requests, expected 5000", test.spec, len(traces))
+        }
+
+        writes := 0
+        for _, trace := range traces {
+            if trace.Key.Hi != 0 || trace.Key.Lo < test.low || trace.Key.Lo >= test.high {
+                t.Fatalf("%v: address %v out of [%d, %d)", test.spec, trace.Key, test.low, test.high)
+            }
+            if trace.Op == simulator.OpWrite {
+                writes++
+            }
+        }
+        if writes < 1300 || writes > 1700 {
+            t.Errorf("%v: %d writes of 5000, expected about 1500", test.spec, writes)
+        }
+    }
+}
+
+// TestScan checks that scans never revisit an address, even across phases
+func TestScan(t *testing.T) {
+    seen := make(map[simulator.Key]bool)
+    for _, trace := range generate(t, "scan:1000,zipf:1000", 8000, 1) {
+        if trace.Key.Lo < 1000 {
+            continue
+        }
+        if seen[trace.Key] {
+            t.Fatalf("address %v scanned twice", trace.Key)
+        }
+        seen[trace.Key] = true
+    }
+    if len(seen) != 4000 {
+        t.Errorf("%d addresses scanned, expected 4000", len(seen))
+    }
+}
+
+// TestZipf checks that the first addresses are the most requested
+func TestZipf(t *testing.T) {
+    counts := make([]int, options.Addresses)
+    for _, trace := range generate(t, "zipf", 100000, 1) {
+        counts[trace.Key.Lo]++
+    }
+    if counts[0] <= counts[10] || counts[10] <= counts[500] {
+        t.Errorf("%d requests of address 0, %d of 10 and %d of 500, expected decreasing", counts[0], counts[10], counts[500])
+    }
+}
@@ -2,4 +2,8 @@ module github.com/mohammadtauchid/golang-cache/v2
 
 go 1.19
 
-require github.com/secnot/orderedmap v0.0.0-20170705091748-a05363cca499
+require (
+	github.com/klauspost/compress v1.17.4
+	github.com/secnot/orderedmap v0.0.0-20170705091748-a05363cca499
+	github.com/ulikunitz/xz v0.5.11
+)
@@ -0,0 +1,297 @@
+package hierarchy
+
+import (
+    "fmt"
+    "io"
+    "strings"
+    "time"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// Inclusion is the relation kept between the contents of the tiers
+type Inclusion int
+
+const (
+    // NonInclusive fills every tier on a miss and lets them evict freely
+    NonInclusive Inclusion = iota
+    // Inclusive fills every tier on a miss, and a page evicted from a tier
+    // is invalidated in the tiers above it
+    Inclusive
+    // Exclusive keeps a page in a single tier: misses fill the first tier,
+    // hits in a lower tier move the page up, evictions are demoted one tier
+    Exclusive
+)
+
+// Inclusions lists the names accepted by ParseInclusion
+var Inclusions = []string{"non-inclusive", "inclusive", "exclusive"}
+
+func ParseInclusion(name string) (inclusion Inclusion, err error) {
+    for i, known := range Inclusions {
+        if strings.ToLower(strings.TrimSpace(name)) == known {
+            return Inclusion(i), nil
+        }
+    }
+    return NonInclusive, fmt.Errorf("unknown inclusion %q, expected one of %v", name, Inclusions)
+}
+
+func (inclusion Inclusion) String() string {
+    if int(inclusion) < len(Inclusions) {
+        return Inclusions[inclusion]
+    }
+    return fmt.Sprintf("Inclusion(%d)", int(inclusion))
+}
+
+type (
+    // Tier is one level of the hierarchy, e.g. DRAM or flash
+    Tier struct {
+        Name        string
+        Policy      simulator.Policy
+        Latency     float64 // cost of a lookup in the tier, in microseconds
+
+        hit         int
+        write       int // pages written into the tier, including demotions
+        evict       int
+        demoted     int // pages demoted into the tier from the one above
+        invalidated int // pages removed to keep the hierarchy inclusive
+        observers   []simulator.Observer
+    }
+
+    // DemotionObserver is implemented by observers telling the pages
+    // demoted into a tier apart from the requests of the host
+    DemotionObserver interface {
+        ObserveDemotion(trace simulator.Trace, before simulator.Stats, after simulator.Stats)
+    }
+
+    // eviction is a page evicted from a tier, handled once the request
+    // that caused it returns
+    eviction struct {
+        tier        int
+        lba         simulator.Key
+    }
+
+    // Hierarchy composes policies into tiers placed in front of a backing
+    // store. A request is looked up tier by tier, and served by the backing
+    // store when no tier holds it.
+    Hierarchy struct {
+        tiers       []*Tier
+        inclusion   Inclusion
+        backing     float64 // latency of the backing store, in microseconds
+
+        hit         int
+        miss        int
+        deleted     int
+        last        float64 // estimated service time of the last request
+
+        pending     []eviction
+    }
+)
+
+func NewTier(name string, policy simulator.Policy, latency float64) *Tier {
+    return &Tier{
+        Name:       name,
+        Policy:     policy,
+        Latency:    latency,
+    }
+}
+
+func NewHierarchy(inclusion Inclusion, backing float64, tiers ...*Tier) *Hierarchy {
+    h := &Hierarchy{
+        tiers:      tiers,
+        inclusion:  inclusion,
+        backing:    backing,
+        hit:        0,
+        miss:       0,
+        deleted:    0,
+        pending:    make([]eviction, 0),
+    }
+
+    for i, tier := range tiers {
+        i := i
+        tier.Policy.OnEvict(func(lba simulator.Key) {
+            h.pending = append(h.pending, eviction{tier: i, lba: lba})
+        })
+    }
+
+    return h
+}
+
+// AddObserver registers an observer notified of every request served by
+// the tier, with the counters of the tier. Demotions are notified to
+// ObserveDemotion when the observer is a DemotionObserver, and to Observe
+// as a write otherwise.
+func (tier *Tier) AddObserver(observer simulator.Observer) {
+    tier.observers = append(tier.observers, observer)
+}
+
+// serve forwards trace to the policy of the tier; demotion tells a page
+// demoted from the tier above
+func (tier *Tier) serve(trace simulator.Trace, demotion bool) {
+    before := tier.Policy.Stats()
+
+    tier.Policy.Get(trace)
+
+    after := tier.Policy.Stats()
+    tier.write += after.Write - before.Write
+    for _, observer := range tier.observers {
+        if demoted, ok := observer.(DemotionObserver); ok && demotion {
+            demoted.ObserveDemotion(trace, before, after)
+        } else {
+            observer.Observe(trace, before, after)
+        }
+    }
+}
+
+// Tiers returns the tiers, from the first looked up to the last
+func (h *Hierarchy) Tiers() []*Tier {
+    return h.tiers
+}
+
+// access serves trace in a tier and handles the evictions it caused
+func (h *Hierarchy) access(index int, trace simulator.Trace) {
+    h.tiers[index].serve(trace, false)
+
+    for len(h.pending) > 0 {
+        evicted := h.pending[0]
+        h.pending = h.pending[1:]
+        h.tiers[evicted.tier].evict++
+
+        switch h.inclusion {
+        case Inclusive:
+            for upper := 0; upper < evicted.tier; upper++ {
+                if h.tiers[upper].Policy.Remove(evicted.lba) {
+                    h.tiers[upper].invalidated++
+                }
+            }
+        case Exclusive:
+            if evicted.tier + 1 < len(h.tiers) {
+                lower := h.tiers[evicted.tier + 1]
+                lower.demoted++
+                lower.serve(simulator.Trace{
+                    Key:        evicted.lba,
+                    Op:         simulator.OpWrite,
+                    Timestamp:  trace.Timestamp,
+                }, true)
+            }
+        }
+    }
+}
+
+func (h *Hierarchy) Get(trace simulator.Trace) (err error) {
+    if trace.Op == simulator.OpDelete {
+        h.last = 0
+        removed := false
+        for _, tier := range h.tiers {
+            if tier.Policy.Remove(trace.Key) {
+                removed = true
+            }
+        }
+        if removed {
+            h.deleted++
+        }
+        return nil
+    }
+
+    h.last = 0
+
+    level := len(h.tiers)
+    for i, tier := range h.tiers {
+        h.last += tier.Latency
+        if tier.Policy.Contains(trace.Key) {
+            level = i
+            break
+        }
+    }
+
+    if level < len(h.tiers) {
+        h.tiers[level].hit++
+        h.hit++
+    } else {
+        h.last += h.backing
+        h.miss++
+    }
+
+    if h.inclusion == Exclusive {
+        if level > 0 && level < len(h.tiers) {
+            h.tiers[level].Policy.Remove(trace.Key)
+        }
+        h.access(0, trace)
+        return nil
+    }
+
+    // fill the lower tiers first, so that invalidations caused by their
+    // evictions never hit the page being filled above
+    if level == len(h.tiers) {
+        level--
+    }
+    for i := level; i >= 0; i-- {
+        h.access(i, trace)
+    }
+
+    return nil
+}
+
+// SetPurgeGhosts forwards the option to the tiers keeping ghost lists
+func (h *Hierarchy) SetPurgeGhosts(purge bool) {
+    for _, tier := range h.tiers {
+        if purger, ok := tier.Policy.(interface{ SetPurgeGhosts(bool) }); ok {
+            purger.SetPurgeGhosts(purge)
+        }
+    }
+}
+
+func (h *Hierarchy) Stats() (stats simulator.Stats) {
+    stats = simulator.Stats{
+        Hit:        h.hit,
+        Miss:       h.miss,
+        Delete:     h.deleted,
+    }
+
+    for _, tier := range h.tiers {
+        tierStats := tier.Policy.Stats()
+        stats.Write += tier.write
+        stats.Eviction += tier.evict
+        stats.Occupancy += tierStats.Occupancy
+        stats.Capacity += tierStats.Capacity
+    }
+
+    return stats
+}
+
+// LastLatency returns the estimated time spent on the last request, in
+// microseconds: the lookups in every tier down to the one holding the page,
+// plus the backing store on a miss
+func (h *Hierarchy) LastLatency() float64 {
+    return h.last
+}
+
+func (h *Hierarchy) PrintToFile(file io.Writer, elapsed time.Duration) (err error) {
+    stats := h.Stats()
+    requests := h.hit + h.miss
+
+    fmt.Fprintf(file, "cache size: %d\n", stats.Capacity)
+    fmt.Fprintf(file, "inclusion: %v\n", h.inclusion)
+    fmt.Fprintf(file, "cache hit: %d\n", h.hit)
+    fmt.Fprintf(file, "cache miss: %d\n", h.miss)
+    fmt.Fprintf(file, "cache hit ratio: %.4f%%\n", float64(h.hit) / float64(requests) * 100)
+    fmt.Fprintf(file, "write count: %d\n", stats.Write)
+    fmt.Fprintf(file, "delete count: %d\n", h.deleted)
+
+    for i, tier := range h.tiers {
+        tierStats := tier.Policy.Stats()
+        fmt.Fprintf(file, "tier %d (%v, %d pages):\n", i + 1, tier.Name, tierStats.Capacity)
+        fmt.Fprintf(file, "    hit: %d (%.4f%% of requests)\n", tier.hit, float64(tier.hit) / float64(requests) * 100)
+        fmt.Fprintf(file, "    write count: %d\n", tier.write)
+        fmt.Fprintf(file, "    eviction count: %d\n", tier.evict)
+        if h.inclusion == Exclusive && i > 0 {
+            fmt.Fprintf(file, "    demotion count: %d\n", tier.demoted)
+        }
+        if h.inclusion == Inclusive && i < len(h.tiers) - 1 {
+            fmt.Fprintf(file, "    invalidation count: %d\n", tier.invalidated)
+        }
+    }
+
+    fmt.Fprintf(file, "time execution: %8.4f\n", elapsed.Seconds())
+
+    return nil
+}
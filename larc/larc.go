@@ -1,8 +1,9 @@
 package larc
 
 import (
+    "encoding/gob"
     "fmt"
-	"os"
+	"io"
 	"time"
     "sort"
 
@@ -12,8 +13,8 @@ import (
 
 type (
     Node struct {
-        lba int
-        op  string
+        lba simulator.Key
+        op  simulator.Op
     }
 
     LARC struct {
@@ -22,11 +23,28 @@ type (
         hit         int
         miss        int
         wc          int
+        evict       int
+        deleted     int
+        onEvict     func(lba simulator.Key) // notified of every evicted page
 
         q           *orderedmap.OrderedMap
-        qr          []int
+        qr          []simulator.Key
         cr          int
     }
+
+    // state is what a snapshot saves of a LARC
+    state struct {
+        Maxlen      int
+        Available   int
+        Hit         int
+        Miss        int
+        Wc          int
+        Evict       int
+        Deleted     int
+        Q           []simulator.Entry
+        Qr          []simulator.Key
+        Cr          int
+    }
 )
 
 func NewLARC(value int) *LARC {
@@ -36,16 +54,38 @@ func NewLARC(value int) *LARC {
         hit:        0,
         miss:       0,
         wc:         0,
+        evict:      0,
+        deleted:    0,
 
         q:      orderedmap.NewOrderedMap(),
-        qr:     make([]int, int(0.1 * float64(value))),
-        cr:     int(0.1 * float64(value)),
+        qr:     make([]simulator.Key, int(0.1 * float64(value))),
+        cr:     boundCr(int(0.1 * float64(value)), value),
+    }
+}
+
+// boundCr keeps the size of the candidate queue between 10% and 90% of the
+// cache, and at least a page so that tiny caches can adapt too
+func boundCr(cr int, maxlen int) int {
+    low, high := int(0.1 * float64(maxlen)), int(0.9 * float64(maxlen))
+    if low < 1 {
+        low = 1
+    }
+    if high < low {
+        high = low
+    }
+
+    if cr < low {
+        return low
+    }
+    if cr > high {
+        return high
     }
+    return cr
 }
 
-func getIndex(slice []int, target int) (index int, ok bool) {
+func getIndex(slice []simulator.Key, target simulator.Key) (index int, ok bool) {
     index = sort.Search(len(slice), func(i int) bool {
-        return slice[i] >= target
+        return !slice[i].Less(target)
     })
 
     if index < len(slice) && slice[index] == target {
@@ -74,16 +114,18 @@ func (larc *LARC) Filter(data *Node) (exists bool) {
 }
 
 func (larc *LARC) Put(data *Node) (exists bool) {
+    key := data.lba.MapKey()
+
     // cache hit
-    if _, ok := larc.q.Get(data.lba); ok {
+    if _, ok := larc.q.Get(key); ok {
         larc.hit++
-        larc.q.MoveLast(data.lba)
+        larc.q.MoveLast(key)
 
         // resize qr
-        larc.cr = larc.cr - larc.maxlen / (larc.maxlen - larc.cr)
-        if float64(larc.cr) < 0.1 * float64(larc.maxlen) {
-            larc.cr = int(0.1 * float64(larc.maxlen))
+        if larc.maxlen > larc.cr {
+            larc.cr = larc.cr - larc.maxlen / (larc.maxlen - larc.cr)
         }
+        larc.cr = boundCr(larc.cr, larc.maxlen)
         size := larc.cr
         if size > len(larc.qr) {
             size = len(larc.qr)
@@ -97,10 +139,7 @@ func (larc *LARC) Put(data *Node) (exists bool) {
     larc.miss++
 
     // resize qr
-    larc.cr = larc.cr + (larc.maxlen / larc.cr)
-    if float64(larc.cr) > 0.9 * float64(larc.maxlen) {
-        larc.cr = int(0.9 * float64(larc.maxlen))
-    }
+    larc.cr = boundCr(larc.cr + (larc.maxlen / larc.cr), larc.maxlen)
     size := larc.cr
     if size > len(larc.qr) {
         size = len(larc.qr)
@@ -115,31 +154,161 @@ func (larc *LARC) Put(data *Node) (exists bool) {
 
     if larc.available > 0 {
         larc.available--
-        larc.q.Set(data.lba, data.op)
+        larc.q.Set(key, data.op)
     } else {
-        larc.q.PopFirst()
-        larc.q.Set(data.lba, data.op)
+        evictedKey, _, _ := larc.q.PopFirst()
+        larc.evicted(evictedKey)
+        larc.q.Set(key, data.op)
     }
 
     return false
 }
 
+// Remove drops lba from the cache and from the candidate filter, returning
+// whether it was cached
+func (larc *LARC) Remove(lba simulator.Key) (removed bool) {
+    key := lba.MapKey()
+
+    for i, candidate := range larc.qr {
+        if candidate == lba {
+            larc.qr = append(larc.qr[:i], larc.qr[i + 1:]...)
+            break
+        }
+    }
+
+    if _, ok := larc.q.Get(key); !ok {
+        return false
+    }
+
+    larc.q.Delete(key)
+    larc.available++
+    return true
+}
+
+// Contains reports whether lba is cached, without updating the lists
+func (larc *LARC) Contains(lba simulator.Key) bool {
+    _, ok := larc.q.Get(lba.MapKey())
+    return ok
+}
+
+// Keys returns the cached pages from the least to the most recently used
+func (larc *LARC) Keys() (keys []simulator.Key) {
+    iter := larc.q.Iter()
+    for key, _, ok := iter.Next(); ok; key, _, ok = iter.Next() {
+        keys = append(keys, simulator.KeyOf(key))
+    }
+    return keys
+}
+
+// OnEvict registers fn to be called with every page evicted from the cache
+func (larc *LARC) OnEvict(fn func(lba simulator.Key)) {
+    larc.onEvict = fn
+}
+
+func (larc *LARC) evicted(key interface{}) {
+    // an empty list, as in a cache of no pages, has nothing to evict
+    if key == nil {
+        return
+    }
+    larc.evict++
+    if larc.onEvict != nil {
+        larc.onEvict(simulator.KeyOf(key))
+    }
+}
+
+// Resize changes the capacity of the cache, evicting the least recently
+// used pages when it shrinks
+func (larc *LARC) Resize(value int) {
+    larc.available += value - larc.maxlen
+    larc.maxlen = value
+
+    for larc.available < 0 {
+        evictedKey, _, _ := larc.q.PopFirst()
+        larc.evicted(evictedKey)
+        larc.available++
+    }
+
+    // keep the candidate queue within its bounds for the new size
+    larc.cr = boundCr(larc.cr, value)
+    if len(larc.qr) > larc.cr {
+        larc.qr = larc.qr[len(larc.qr) - larc.cr:]
+    }
+}
+
 func (larc *LARC) Get(trace simulator.Trace) (err error) {
+    if trace.Op == simulator.OpDelete {
+        if larc.Remove(trace.Key) {
+            larc.deleted++
+        }
+        return nil
+    }
+
     obj := new(Node)
-    obj.lba = trace.Addr
+    obj.lba = trace.Key
     obj.op = trace.Op
     larc.Put(obj)
 
     return nil
 }
 
-func (larc *LARC) PrintToFile(file *os.File, start time.Time) (err error) {
-    file.WriteString(fmt.Sprintf("cache size: %d\n", larc.maxlen))
-    file.WriteString(fmt.Sprintf("cache hit: %d\n", larc.hit))
-    file.WriteString(fmt.Sprintf("cache miss: %d\n", larc.miss))
-    file.WriteString(fmt.Sprintf("cache hit ratio: %.4f%%\n", float64(larc.hit) / float64(larc.hit + larc.miss) * 100))
-    file.WriteString(fmt.Sprintf("write count: %d\n", larc.wc))
-    file.WriteString(fmt.Sprintf("time execution: %8.4f\n", time.Since(start).Seconds()))
+func (larc *LARC) Stats() simulator.Stats {
+    return simulator.Stats{
+        Hit:        larc.hit,
+        Miss:       larc.miss,
+        Write:      larc.wc,
+        Eviction:   larc.evict,
+        Delete:     larc.deleted,
+        Occupancy:  larc.q.Len(),
+        Capacity:   larc.maxlen,
+    }
+}
+
+func (larc *LARC) PrintToFile(file io.Writer, elapsed time.Duration) (err error) {
+    fmt.Fprintf(file, "cache size: %d\n", larc.maxlen)
+    fmt.Fprintf(file, "cache hit: %d\n", larc.hit)
+    fmt.Fprintf(file, "cache miss: %d\n", larc.miss)
+    fmt.Fprintf(file, "cache hit ratio: %.4f%%\n", float64(larc.hit) / float64(larc.hit + larc.miss) * 100)
+    fmt.Fprintf(file, "write count: %d\n", larc.wc)
+    fmt.Fprintf(file, "delete count: %d\n", larc.deleted)
+    fmt.Fprintf(file, "time execution: %8.4f\n", elapsed.Seconds())
 
     return nil
-}   
\ No newline at end of file
+}   
+
+// Checkpoint saves the cache, the candidate filter and the counters
+func (larc *LARC) Checkpoint(enc *gob.Encoder) (err error) {
+    return enc.Encode(state{
+        Maxlen:     larc.maxlen,
+        Available:  larc.available,
+        Hit:        larc.hit,
+        Miss:       larc.miss,
+        Wc:         larc.wc,
+        Evict:      larc.evict,
+        Deleted:    larc.deleted,
+        Q:          simulator.SaveList(larc.q),
+        Qr:         larc.qr,
+        Cr:         larc.cr,
+    })
+}
+
+// Restore replaces the state of the cache by one saved by Checkpoint
+func (larc *LARC) Restore(dec *gob.Decoder) (err error) {
+    var s state
+
+    if err = dec.Decode(&s); err != nil {
+        return err
+    }
+
+    larc.maxlen = s.Maxlen
+    larc.available = s.Available
+    larc.hit = s.Hit
+    larc.miss = s.Miss
+    larc.wc = s.Wc
+    larc.evict = s.Evict
+    larc.deleted = s.Deleted
+    larc.q = simulator.LoadList(s.Q)
+    larc.qr = append(make([]simulator.Key, 0, len(s.Qr)), s.Qr...)
+    larc.cr = s.Cr
+
+    return nil
+}
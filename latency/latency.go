@@ -0,0 +1,179 @@
+package latency
+
+import (
+    "fmt"
+    "io"
+    "math"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+const (
+    // subBuckets divides every power of two of the histogram, so that
+    // percentiles are within 1% of the response times
+    subBuckets = 128
+    // minExponent and maxExponent bound the powers of two of the histogram,
+    // from a nanosecond to over a year in microseconds; shorter times are
+    // counted as zero and longer ones in the last bucket
+    minExponent = -10
+    maxExponent = 45
+)
+
+type (
+    // Model gives the cost of the operations of a cache, in microseconds
+    Model struct {
+        Hit         float64 // lookup of a page, paid by every request
+        Miss        float64 // fetch from the backing store
+        Write       float64 // writing a page into the cache
+        Servers     int     // requests served concurrently, 0 disables queueing
+    }
+
+    // Estimator is implemented by simulators that estimate the lookup and
+    // fetch time of each request themselves, e.g. with per-tier costs
+    Estimator interface {
+        LastLatency() float64
+    }
+
+    // Recorder applies a Model to every request of a run. With queueing,
+    // requests arrive at their trace timestamp and wait for one of the
+    // servers to be free, so the response time includes the queueing delay.
+    // Response times are counted in a histogram of logarithmic buckets, as
+    // in HdrHistogram, so the memory does not grow with the trace.
+    Recorder struct {
+        model       Model
+        estimator   Estimator
+
+        buckets     []int
+        sums        []float64 // sum of the response times of every bucket
+        count       int
+        total       float64 // sum of the response times
+        service     float64 // sum of the service times
+        free        []float64 // time each server becomes free
+        first       float64 // arrival of the first request
+        last        float64 // completion of the last request
+    }
+)
+
+// NewRecorder creates a recorder for the requests served by sim
+func NewRecorder(model Model, sim simulator.Simulator) *Recorder {
+    r := &Recorder{
+        model:      model,
+        buckets:    make([]int, (maxExponent - minExponent) * subBuckets + 1),
+        sums:       make([]float64, (maxExponent - minExponent) * subBuckets + 1),
+        free:       make([]float64, model.Servers),
+    }
+
+    if estimator, ok := sim.(Estimator); ok {
+        r.estimator = estimator
+    }
+
+    return r
+}
+
+func (r *Recorder) Observe(trace simulator.Trace, before simulator.Stats, after simulator.Stats) {
+    var service, response float64
+
+    switch {
+    case r.estimator != nil:
+        service = r.estimator.LastLatency()
+    case after.Hit > before.Hit || trace.Op == simulator.OpDelete:
+        service = r.model.Hit
+    default:
+        service = r.model.Hit + r.model.Miss
+    }
+    service += r.model.Write * float64(after.Write - before.Write)
+
+    response = service
+    if r.model.Servers > 0 {
+        arrival := trace.Timestamp * 1e6
+        if r.count == 0 {
+            r.first = arrival
+        }
+
+        server := 0
+        for i := range r.free {
+            if r.free[i] < r.free[server] {
+                server = i
+            }
+        }
+
+        start := math.Max(arrival, r.free[server])
+        r.free[server] = start + service
+        response = r.free[server] - arrival
+        r.last = math.Max(r.last, r.free[server])
+    }
+
+    index := bucket(response)
+    r.buckets[index]++
+    r.sums[index] += response
+    r.count++
+    r.total += response
+    r.service += service
+}
+
+// bucket returns the histogram bucket counting the response time v
+func bucket(v float64) int {
+    frac, exp := math.Frexp(v)
+    switch {
+    case !(v > 0) || exp <= minExponent:
+        return 0
+    case exp > maxExponent || math.IsInf(v, 1):
+        return (maxExponent - minExponent) * subBuckets
+    }
+    // frac is in [0.5, 1), split into the sub-buckets of the power of two
+    return (exp - minExponent - 1) * subBuckets + int((frac - 0.5) * 2 * subBuckets) + 1
+}
+
+// Mean returns the average response time in microseconds
+func (r *Recorder) Mean() float64 {
+    if r.count == 0 {
+        return 0
+    }
+    return r.total / float64(r.count)
+}
+
+// Percentile returns the response time below which the fraction p of the
+// requests fall, in microseconds: the mean of its bucket, which is exact
+// when the requests of the bucket took the same time
+func (r *Recorder) Percentile(p float64) float64 {
+    if r.count == 0 {
+        return 0
+    }
+
+    rank := int(math.Ceil(p * float64(r.count)))
+    if rank < 1 {
+        rank = 1
+    }
+    seen := 0
+    for index, count := range r.buckets {
+        seen += count
+        if seen >= rank {
+            return r.sums[index] / float64(count)
+        }
+    }
+    return 0
+}
+
+// IOPS returns the requests served per second: over the span of the trace
+// with queueing, otherwise back to back on a single server
+func (r *Recorder) IOPS() float64 {
+    if r.model.Servers > 0 && r.last > r.first {
+        return float64(r.count) / ((r.last - r.first) / 1e6)
+    }
+    if r.service == 0 {
+        return 0
+    }
+    return float64(r.count) / (r.service / 1e6)
+}
+
+func (r *Recorder) PrintToFile(file io.Writer) (err error) {
+    if r.model.Servers > 0 {
+        fmt.Fprintf(file, "latency queueing: %d servers\n", r.model.Servers)
+    }
+    fmt.Fprintf(file, "latency average: %.4f us\n", r.Mean())
+    fmt.Fprintf(file, "latency p50: %.4f us\n", r.Percentile(0.5))
+    fmt.Fprintf(file, "latency p99: %.4f us\n", r.Percentile(0.99))
+    fmt.Fprintf(file, "effective iops: %.2f\n", r.IOPS())
+
+    return nil
+}
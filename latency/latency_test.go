@@ -0,0 +1,70 @@
+package latency
+
+import (
+    "math"
+    "math/rand"
+    "sort"
+    "testing"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// stub is a simulator estimating its own service times
+type stub struct {
+    simulator.Simulator
+    latency     float64
+}
+
+func (s *stub) LastLatency() float64 {
+    return s.latency
+}
+
+// TestPercentiles checks the percentiles of the histogram against those of
+// the sorted response times
+func TestPercentiles(t *testing.T) {
+    tests := map[string]func(r *rand.Rand) float64{
+        "hits and misses":  func(r *rand.Rand) float64 { return []float64{100, 100, 100, 10100}[r.Intn(4)] },
+        "exponential":      func(r *rand.Rand) float64 { return r.ExpFloat64() * 1000 },
+        "wide":             func(r *rand.Rand) float64 { return math.Pow(10, r.Float64() * 9 - 2) },
+    }
+
+    for name, draw := range tests {
+        var (
+            random  *rand.Rand = rand.New(rand.NewSource(1))
+            sim     *stub = &stub{}
+            r       *Recorder = NewRecorder(Model{}, sim)
+            samples []float64
+        )
+
+        for i := 0; i < 100000; i++ {
+            sim.latency = draw(random)
+            samples = append(samples, sim.latency)
+            r.Observe(simulator.Trace{}, simulator.Stats{}, simulator.Stats{})
+        }
+        sort.Float64s(samples)
+
+        for _, p := range []float64{0.01, 0.5, 0.9, 0.99, 0.999, 1} {
+            expected := samples[int(math.Ceil(p * float64(len(samples)))) - 1]
+            if got := r.Percentile(p); math.Abs(got - expected) > expected * 0.01 {
+                t.Errorf("%v: p%v %v, expected %v", name, p * 100, got, expected)
+            }
+        }
+        if name == "hits and misses" && (r.Percentile(0.5) != 100 || r.Percentile(0.99) != 10100) {
+            t.Errorf("%v: p50 %v and p99 %v, expected exactly 100 and 10100", name, r.Percentile(0.5), r.Percentile(0.99))
+        }
+        if len(r.buckets) != (maxExponent - minExponent) * subBuckets + 1 {
+            t.Errorf("%v: histogram grew to %d buckets", name, len(r.buckets))
+        }
+    }
+}
+
+func TestBucket(t *testing.T) {
+    previous := 0
+    for _, v := range []float64{0, 1e-9, 0.001, 0.5, 1, 1.001, 100, 100.5, 10100, 1e13, 1e20, math.Inf(1)} {
+        index := bucket(v)
+        if index < previous || index >= (maxExponent - minExponent) * subBuckets + 1 {
+            t.Errorf("%v in bucket %d, after %d", v, index, previous)
+        }
+        previous = index
+    }
+}
@@ -2,6 +2,7 @@ package lfu
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"time"
 
@@ -11,8 +12,8 @@ import (
 
 type (
 	Node struct {
-        lba     int
-        op      string
+        lba     simulator.Key
+        op      simulator.Op
         freq    int
     }
 
@@ -21,6 +22,7 @@ type (
         available   int
         hit         int
         miss        int
+        evict       int
 
         list        *orderedmap.OrderedMap  
     }
@@ -32,11 +34,14 @@ func NewLFU(value int) *LFU {
         available:      value,
         hit:            0,
         miss:           0,
+        evict:          0,
         list:           orderedmap.NewOrderedMap(),
     }
 }
 
 func (lfu *LFU) Put(data *Node) (exists bool) {
+    key := data.lba.MapKey()
+
     log, err := os.OpenFile("lfu.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
     if err != nil {
         fmt.Println("Error opening file:", err)
@@ -48,14 +53,14 @@ func (lfu *LFU) Put(data *Node) (exists bool) {
         log.WriteString(time.Now().String() + " : LFU cache is empty\n")
     }
 
-    if _, ok := lfu.list.Get(data.lba); ok {
+    if _, ok := lfu.list.Get(key); ok {
         lfu.hit++
         data.freq++
 
-        if ok := lfu.list.MoveLast(data.lba); !ok {
+        if ok := lfu.list.MoveLast(key); !ok {
             log.WriteString(
                 fmt.Sprintf(
-                    "%s : Failed to move LBA %d to MRU position\n", 
+                    "%s : Failed to move LBA %v to MRU position\n", 
                     time.Now().String(), 
                     data.lba,
                 ),
@@ -71,7 +76,7 @@ func (lfu *LFU) Put(data *Node) (exists bool) {
             lfu.available--
         } else {
             minFreq := 0
-            evictedLBA := 0
+            var evictedLBA simulator.Key
 
             iter := lfu.list.Iter()
             for key, _, ok := iter.Next(); ok; key, _, ok = iter.Next() {
@@ -79,32 +84,44 @@ func (lfu *LFU) Put(data *Node) (exists bool) {
 				node := item.(*Node)
 				if minFreq == -1 || node.freq < minFreq {
 					minFreq = node.freq
-					evictedLBA = key.(int)
+					evictedLBA = simulator.KeyOf(key)
 				}
 			}
-            lfu.list.Delete(evictedLBA)
+            lfu.list.Delete(evictedLBA.MapKey())
+            lfu.evict++
         }
 
-        lfu.list.Set(data.lba, data)
+        lfu.list.Set(key, data)
         return false
     }
 }
 
 func (lfu *LFU) Get(trace simulator.Trace) (err error) {
     obj := new(Node)
-    obj.lba = trace.Addr
+    obj.lba = trace.Key
     obj.op = trace.Op
     lfu.Put(obj)
 
     return nil
 }
 
-func (lfu *LFU) PrintToFile(file *os.File, start time.Time) (err error) {
-    file.WriteString(fmt.Sprintf("cache size: %d\n", lfu.maxlen))
-    file.WriteString(fmt.Sprintf("cache hit: %d\n", lfu.hit))
-    file.WriteString(fmt.Sprintf("cache miss: %d\n", lfu.miss))
-    file.WriteString(fmt.Sprintf("cache hit ratio: %.4f%%\n", float64(lfu.hit) / float64(lfu.hit + lfu.miss) * 100))
-    file.WriteString(fmt.Sprintf("time execution: %8.4f\n", time.Since(start).Seconds()))
+func (lfu *LFU) Stats() simulator.Stats {
+    return simulator.Stats{
+        Hit:        lfu.hit,
+        Miss:       lfu.miss,
+        Write:      lfu.miss,
+        Eviction:   lfu.evict,
+        Occupancy:  lfu.list.Len(),
+        Capacity:   lfu.maxlen,
+    }
+}
+
+func (lfu *LFU) PrintToFile(file io.Writer, elapsed time.Duration) (err error) {
+    fmt.Fprintf(file, "cache size: %d\n", lfu.maxlen)
+    fmt.Fprintf(file, "cache hit: %d\n", lfu.hit)
+    fmt.Fprintf(file, "cache miss: %d\n", lfu.miss)
+    fmt.Fprintf(file, "cache hit ratio: %.4f%%\n", float64(lfu.hit) / float64(lfu.hit + lfu.miss) * 100)
+    fmt.Fprintf(file, "time execution: %8.4f\n", elapsed.Seconds())
 
     return nil
 }
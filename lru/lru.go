@@ -1,8 +1,9 @@
 package lru
 
 import (
+    "encoding/gob"
 	"fmt"
-    "os"
+    "io"
     "time"
 
     "github.com/mohammadtauchid/golang-cache/v2/simulator"
@@ -11,8 +12,8 @@ import (
 
 type (
 	Node struct {
-		lba int
-        op  string
+		lba simulator.Key
+        op  simulator.Op
 	}
 
     LRU struct {
@@ -21,9 +22,24 @@ type (
         hit         int
         miss        int
         wc          int
+        evict       int
+        deleted     int
+        onEvict     func(lba simulator.Key) // notified of every evicted page
 
         list        *orderedmap.OrderedMap
     }
+
+    // state is what a snapshot saves of an LRU
+    state struct {
+        Maxlen      int
+        Available   int
+        Hit         int
+        Miss        int
+        Wc          int
+        Evict       int
+        Deleted     int
+        List        []simulator.Entry
+    }
 )
 
 func NewLRU(value int) *LRU {
@@ -33,16 +49,19 @@ func NewLRU(value int) *LRU {
         hit:            0,
         miss:           0,
         wc:             0,
+        evict:          0,
+        deleted:        0,
         list:           orderedmap.NewOrderedMap(),
     }
 }
 
 func (lru *LRU) Put(data *Node) (exists bool) {
+    key := data.lba.MapKey()
 
-    if _, ok := lru.list.Get(data.lba); ok {
+    if _, ok := lru.list.Get(key); ok {
         lru.hit++
 
-        if ok := lru.list.MoveLast(data.lba); !ok {
+        if ok := lru.list.MoveLast(key); !ok {
             return
         }
         
@@ -56,29 +75,141 @@ func (lru *LRU) Put(data *Node) (exists bool) {
         } else {
             evictedLBA, _, _ := lru.list.GetFirst()
             lru.list.Delete(evictedLBA)
+            lru.evicted(evictedLBA)
         }
         
-        lru.list.Set(data.lba, data.op)
+        lru.list.Set(key, data.op)
         return false
     }   
 }
 
+// Remove drops lba from the cache, returning whether it was cached
+func (lru *LRU) Remove(lba simulator.Key) (removed bool) {
+    key := lba.MapKey()
+
+    if _, ok := lru.list.Get(key); !ok {
+        return false
+    }
+
+    lru.list.Delete(key)
+    lru.available++
+    return true
+}
+
+// Contains reports whether lba is cached, without updating the lists
+func (lru *LRU) Contains(lba simulator.Key) bool {
+    _, ok := lru.list.Get(lba.MapKey())
+    return ok
+}
+
+// Keys returns the cached pages from the least to the most recently used
+func (lru *LRU) Keys() (keys []simulator.Key) {
+    iter := lru.list.Iter()
+    for key, _, ok := iter.Next(); ok; key, _, ok = iter.Next() {
+        keys = append(keys, simulator.KeyOf(key))
+    }
+    return keys
+}
+
+// OnEvict registers fn to be called with every page evicted from the cache
+func (lru *LRU) OnEvict(fn func(lba simulator.Key)) {
+    lru.onEvict = fn
+}
+
+func (lru *LRU) evicted(key interface{}) {
+    // an empty list, as in a cache of no pages, has nothing to evict
+    if key == nil {
+        return
+    }
+    lru.evict++
+    if lru.onEvict != nil {
+        lru.onEvict(simulator.KeyOf(key))
+    }
+}
+
+// Resize changes the capacity of the cache, evicting the least recently
+// used pages when it shrinks
+func (lru *LRU) Resize(value int) {
+    lru.available += value - lru.maxlen
+    lru.maxlen = value
+
+    for lru.available < 0 {
+        evictedLBA, _, _ := lru.list.PopFirst()
+        lru.evicted(evictedLBA)
+        lru.available++
+    }
+}
+
 func (lru *LRU) Get(trace simulator.Trace) (err error) {
+    if trace.Op == simulator.OpDelete {
+        if lru.Remove(trace.Key) {
+            lru.deleted++
+        }
+        return nil
+    }
+
     obj := new(Node)
-    obj.lba = trace.Addr
+    obj.lba = trace.Key
     obj.op = trace.Op
     lru.Put(obj)
 
     return nil
 }
 
-func (lru *LRU) PrintToFile(file *os.File, start time.Time) (err error) {
-    file.WriteString(fmt.Sprintf("cache size: %d\n", lru.maxlen))
-    file.WriteString(fmt.Sprintf("cache hit: %d\n", lru.hit))
-    file.WriteString(fmt.Sprintf("cache miss: %d\n", lru.miss))
-    file.WriteString(fmt.Sprintf("cache hit ratio: %.4f%%\n", float64(lru.hit) / float64(lru.hit + lru.miss) * 100))
-    file.WriteString(fmt.Sprintf("write count: %d\n", lru.wc))
-    file.WriteString(fmt.Sprintf("time execution: %8.4f\n", time.Since(start).Seconds()))
+func (lru *LRU) Stats() simulator.Stats {
+    return simulator.Stats{
+        Hit:        lru.hit,
+        Miss:       lru.miss,
+        Write:      lru.wc,
+        Eviction:   lru.evict,
+        Delete:     lru.deleted,
+        Occupancy:  lru.list.Len(),
+        Capacity:   lru.maxlen,
+    }
+}
+
+func (lru *LRU) PrintToFile(file io.Writer, elapsed time.Duration) (err error) {
+    fmt.Fprintf(file, "cache size: %d\n", lru.maxlen)
+    fmt.Fprintf(file, "cache hit: %d\n", lru.hit)
+    fmt.Fprintf(file, "cache miss: %d\n", lru.miss)
+    fmt.Fprintf(file, "cache hit ratio: %.4f%%\n", float64(lru.hit) / float64(lru.hit + lru.miss) * 100)
+    fmt.Fprintf(file, "write count: %d\n", lru.wc)
+    fmt.Fprintf(file, "delete count: %d\n", lru.deleted)
+    fmt.Fprintf(file, "time execution: %8.4f\n", elapsed.Seconds())
+
+    return nil
+}
+
+// Checkpoint saves the list and the counters
+func (lru *LRU) Checkpoint(enc *gob.Encoder) (err error) {
+    return enc.Encode(state{
+        Maxlen:     lru.maxlen,
+        Available:  lru.available,
+        Hit:        lru.hit,
+        Miss:       lru.miss,
+        Wc:         lru.wc,
+        Evict:      lru.evict,
+        Deleted:    lru.deleted,
+        List:       simulator.SaveList(lru.list),
+    })
+}
+
+// Restore replaces the state of the cache by one saved by Checkpoint
+func (lru *LRU) Restore(dec *gob.Decoder) (err error) {
+    var s state
+
+    if err = dec.Decode(&s); err != nil {
+        return err
+    }
+
+    lru.maxlen = s.Maxlen
+    lru.available = s.Available
+    lru.hit = s.Hit
+    lru.miss = s.Miss
+    lru.wc = s.Wc
+    lru.evict = s.Evict
+    lru.deleted = s.Deleted
+    lru.list = simulator.LoadList(s.List)
 
     return nil
 }
@@ -1,68 +1,258 @@
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
 	"log"
+	"math"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/mohammadtauchid/golang-cache/v2/arc"
-    "github.com/mohammadtauchid/golang-cache/v2/larc"
-    "github.com/mohammadtauchid/golang-cache/v2/marc"
-	// "github.com/mohammadtauchid/golang-cache/v2/lfu"
-	"github.com/mohammadtauchid/golang-cache/v2/lru"
+	"github.com/mohammadtauchid/golang-cache/v2/ensemble"
+	"github.com/mohammadtauchid/golang-cache/v2/flash"
+	"github.com/mohammadtauchid/golang-cache/v2/hierarchy"
+	"github.com/mohammadtauchid/golang-cache/v2/partition"
+	"github.com/mohammadtauchid/golang-cache/v2/prefetch"
+	"github.com/mohammadtauchid/golang-cache/v2/reader"
 	"github.com/mohammadtauchid/golang-cache/v2/simulator"
 )
 
 func main() {
 	var (
         traces      []simulator.Trace = make([]simulator.Trace, 0)
-        simulator   simulator.Simulator
-        timeStart   time.Time
         out         *os.File
         fs          os.FileInfo
         filePath    string
         outPath     string
         algorithm   string
         algorithms  []string
+        jobs        []job
+        workers     int
+        opts        runOptions
         err         error
-        cacheList   []int
+        cacheList   []cacheSize
+        workingSet  int
+        format      string
+        readOptions reader.Options
+        tiers       string
+        inclusion   string
+        useFlash    bool
+        partitioned string
+        candidates  string
+        device      flash.Device
     )
 
-    if len(os.Args) < 4 {
-        fmt.Println("Usage: ./main [algorithm] [trace file path] [trace size]...")
+    if len(os.Args) > 1 && os.Args[1] == "analyze" {
+        analyze(os.Args[2:])
+        return
+    }
+
+    if len(os.Args) > 1 && os.Args[1] == "generate" {
+        generate(os.Args[2:])
+        return
+    }
+
+    if len(os.Args) > 1 && os.Args[1] == "convert" {
+        convert(os.Args[2:])
+        return
+    }
+
+    if len(os.Args) > 1 && os.Args[1] == "server" {
+        serve(os.Args[2:])
+        return
+    }
+
+    flag.IntVar(&opts.window, "window", 0, "record statistics every N requests")
+    flag.Float64Var(&opts.windowTime, "window-time", 0, "record statistics every T seconds of trace time")
+    flag.StringVar(&opts.warmup, "warmup", "", "exclude a warm-up phase from statistics: N requests, a fraction (\"10%\", \"0.1\") or \"full\"")
+    flag.BoolVar(&opts.purgeGhosts, "purge-ghosts", false, "delete requests also drop the page from the ARC/mARC ghost lists")
+    flag.StringVar(&format, "format", "auto", fmt.Sprintf("trace format, one of %v", reader.Formats))
+    flag.BoolVar(&readOptions.SkipBadLines, "skip-bad-lines", false, "skip trace lines that cannot be parsed")
+    flag.BoolVar(&readOptions.Hex, "hex", false, "trace addresses are hexadecimal")
+    flag.BoolVar(&readOptions.StringKeys, "string-keys", false, "trace addresses are opaque string keys, e.g. URLs")
+    flag.IntVar(&workers, "jobs", runtime.NumCPU(), "number of simulations run concurrently")
+    flag.StringVar(&tiers, "tiers", "", "simulate a hierarchy of tiers, e.g. \"lru:1000@0.1,*@100\": algorithm:size@latency (us), \"*\" is the simulated algorithm and size")
+    flag.StringVar(&inclusion, "inclusion", "non-inclusive", fmt.Sprintf("content relation between tiers, one of %v", hierarchy.Inclusions))
+    flag.BoolVar(&useFlash, "flash", false, "report flash wear: write bytes, block erases and device lifetime")
+    flag.IntVar(&device.PageSize, "page-size", 4096, "bytes per cached page, used when the trace has no request sizes")
+    flag.IntVar(&device.BlockSize, "block-size", 4 << 20, "bytes per flash erase block")
+    flag.Float64Var(&device.TBW, "tbw", 0, "rated endurance of the flash device in terabytes written, for the lifetime estimate")
+    flag.Float64Var(&device.WriteAmplification, "device-wa", 1, "write amplification inside the flash device")
+    flag.Float64Var(&device.Duration, "trace-duration", 0, "seconds covered by the trace, when its timestamps are missing or wrong")
+    flag.BoolVar(&opts.latency, "latency", false, "report estimated service times (average, p50, p99) and effective IOPS")
+    flag.Float64Var(&opts.model.Hit, "hit-latency", 100, "time to look up a page in the cache, in microseconds")
+    flag.Float64Var(&opts.model.Miss, "miss-latency", 10000, "time to fetch a page from the backing store, in microseconds")
+    flag.Float64Var(&opts.model.Write, "write-latency", 0, "time to write a page into the cache, in microseconds")
+    flag.IntVar(&opts.model.Servers, "queue", 0, "model queueing on N servers using the trace timestamps, 0 to disable")
+    flag.StringVar(&opts.prefetch, "prefetch", "", fmt.Sprintf("prefetch into the simulated cache, one of %v with an optional \":degree\"", prefetch.Prefetchers))
+    flag.StringVar(&partitioned, "partition", "", fmt.Sprintf("share the cache between the tenants of the trace: one of %v, e.g. \"static:2,1\" or \"ucp:100000\"", partition.Modes))
+    opts.ensemble = ensemble.DefaultConfig()
+    flag.StringVar(&candidates, "candidates", strings.Join(opts.ensemble.Candidates, ","), "algorithms the ensemble algorithm switches between")
+    flag.Float64Var(&opts.ensemble.SampleRate, "sample-rate", opts.ensemble.SampleRate, "fraction of the keys fed to the ensemble shadow caches; every key when the scaled shadows would hold fewer than 100 pages")
+    flag.IntVar(&opts.ensemble.Window, "switch-window", opts.ensemble.Window, "requests per shard between two ensemble comparisons")
+    flag.Float64Var(&opts.ensemble.Hysteresis, "hysteresis", opts.ensemble.Hysteresis, "hit ratio lead a candidate needs to be switched to")
+    flag.IntVar(&opts.ensemble.Patience, "patience", opts.ensemble.Patience, "consecutive windows a candidate must lead before a switch")
+    flag.IntVar(&opts.ensemble.Shards, "shards", opts.ensemble.Shards, "shards of the ensemble, each switching on its own")
+    flag.StringVar(&opts.checkpoint, "checkpoint", "", "save the state of every simulation to a snapshot in this directory")
+    flag.IntVar(&opts.every, "checkpoint-every", 1000000, "requests between two snapshots")
+    flag.BoolVar(&opts.resume, "resume", false, "continue every simulation from its snapshot in the -checkpoint directory")
+    flag.Parse()
+
+    if flag.NArg() < 3 {
+        fmt.Println("Usage: ./main [options] [algorithm] [trace file path] [trace size]...")
+        fmt.Println("       ./main analyze [options] [trace file path]")
+        fmt.Println("       ./main generate [options] [output file path]")
+        fmt.Println("       ./main convert [options] [trace file path] [output file path]")
+        fmt.Println("       ./main server [options]")
         fmt.Println("Example: ./main LRU resource/Financial 1000 2000 3000")
+        fmt.Println("         ./main -tiers \"lru:1000@0.1,*@100\" -inclusion exclusive mARC resource/Financial 10000")
+        fmt.Println("         ./main -checkpoint snapshots -resume ARC resource/Financial 10000")
+        fmt.Println("Trace sizes may be relative to the unique addresses of the trace, e.g. 1% or 0.1x")
+        fmt.Println("Options:")
+        flag.PrintDefaults()
         fmt.Println("Available algorithms:")
         fmt.Println("LRU     : Least Recently Used")
         // fmt.Println("LFU     : Least Frequently Used")
         fmt.Println("ARC     : Adaptive Replacement Cache")
         // fmt.Println("2Q      : Two Queues")
+        fmt.Println("Ensemble: Switch between the -candidates algorithms")
         fmt.Println("Compare : Compare all algorithms")
         os.Exit(1)
     }
 
-    algorithm = os.Args[1]
+    algorithm = flag.Arg(0)
+
+    if opts.model.Hit < 0 || opts.model.Miss < 0 || opts.model.Write < 0 || opts.model.Servers < 0 {
+        fmt.Println("Error: latencies and queue servers must not be negative")
+        os.Exit(1)
+    }
+
+    if opts.prefetch != "" {
+        if _, err = prefetch.Parse(opts.prefetch); err != nil {
+            fmt.Println(err.Error())
+            os.Exit(1)
+        }
+    }
+
+    if partitioned != "" {
+        config, err := partition.Parse(partitioned)
+        if err != nil {
+            fmt.Println(err.Error())
+            os.Exit(1)
+        }
+        if tiers != "" || opts.prefetch != "" {
+            fmt.Println("Error: -partition cannot be combined with -tiers or -prefetch")
+            os.Exit(1)
+        }
+        opts.partition = &config
+    }
+
+    if opts.resume {
+        if opts.checkpoint == "" {
+            fmt.Println("Error: -resume needs the -checkpoint directory")
+            os.Exit(1)
+        }
+        // observers are not saved in snapshots
+        if opts.window > 0 || opts.windowTime > 0 || opts.warmup != "" || useFlash || opts.latency {
+            fmt.Println("Error: -resume cannot be combined with -window, -window-time, -warmup, -flash or -latency")
+            os.Exit(1)
+        }
+    }
+    if opts.checkpoint != "" {
+        if opts.every < 1 {
+            fmt.Println("Error: -checkpoint-every must be positive")
+            os.Exit(1)
+        }
+        if err = os.MkdirAll(opts.checkpoint, os.ModePerm); err != nil {
+            log.Fatal(err.Error())
+        }
+    }
+
+    if useFlash {
+        if device.PageSize < 1 || device.BlockSize < 0 || device.TBW < 0 || device.WriteAmplification <= 0 {
+            fmt.Println("Error: flash page size, block size, rating and write amplification must be positive")
+            os.Exit(1)
+        }
+        opts.flash = &device
+    }
+
+    if tiers != "" {
+        if opts.tiers, err = parseTiers(tiers); err != nil {
+            fmt.Println(err.Error())
+            os.Exit(1)
+        }
+        if opts.inclusion, err = hierarchy.ParseInclusion(inclusion); err != nil {
+            fmt.Println(err.Error())
+            os.Exit(1)
+        }
+    }
 
-    filePath = os.Args[2]
+    filePath = flag.Arg(1)
     if fs, err = os.Stat(filePath); os.IsNotExist(err) {
         fmt.Printf("Error: %v does not exist", filePath)
         os.Exit(1)
     }
 
-    cacheList, err = validateTraceSize(os.Args[3:])
+    cacheList, err = validateTraceSize(flag.Args()[2:])
     if err != nil {
         fmt.Println(err.Error())
         os.Exit(1)
     }
 
-    traces, err = readFile(filePath)
+    if strings.ToLower(algorithm) == "compare" {
+        algorithms = append(algorithms, "lru", "arc", "larc", "marc")
+    } else {
+        algorithms = append(algorithms, algorithm)
+    }
+
+    opts.ensemble.Candidates = strings.Split(strings.ToLower(candidates), ",")
+    for _, algo := range algorithms {
+        if strings.ToLower(algo) == "ensemble" {
+            if _, err = newEnsemble(opts.ensemble.Shards, opts); err != nil {
+                fmt.Println(err.Error())
+                os.Exit(1)
+            }
+            continue
+        }
+        if _, err = newSimulator(algo, 1); err != nil {
+            log.Fatal("Algorithm not supported")
+        }
+    }
+
+    traces, err = readFile(filePath, format, readOptions)
     if err != nil {
         log.Fatalf("Error reading file: %v", err)
     }
 
+    workingSet = resolveTraceSize(cacheList, traces)
+
+    opts.tenants = simulator.Tenants(traces)
+    if opts.checkpoint != "" {
+        opts.fingerprint = simulator.Fingerprint(traces)
+    }
+
+    for _, algo := range algorithms {
+        for _, cache := range cacheList {
+            jobs = append(jobs, job{
+                index:      len(jobs),
+                algorithm:  algo,
+                cache:      cache.size,
+                relative:   cache.relative,
+                workingSet: workingSet,
+            })
+        }
+    }
+
+    if opts.warmup != "" {
+        if _, err = simulator.ParseWarmUp(opts.warmup, len(traces)); err != nil {
+            fmt.Println(err.Error())
+            os.Exit(1)
+        }
+    }
+
     outPath = fmt.Sprintf("%v_%v_%v.txt", time.Now().Unix(), strings.ToLower(algorithm), fs.Name())
 
     os.MkdirAll(fmt.Sprintf("output/%v", strings.ToLower(algorithm)), os.ModePerm)
@@ -72,99 +262,112 @@ func main() {
     }
     defer out.Close()
 
-    if strings.ToLower(algorithm) == "compare" {
-        algorithms = append(algorithms, "lru", "arc", "larc", "marc")
-    } else {
-        algorithms = append(algorithms, algorithm)
-    }
+    opts.outPrefix = fmt.Sprintf("output/%v/%v", strings.ToLower(algorithm), strings.TrimSuffix(outPath, ".txt"))
 
-    for _, algo := range algorithms {
-        fmt.Println(algo)
-        for _, cache := range cacheList {
-            switch strings.ToLower(algo) {
-            case "lru":
-                simulator = lru.NewLRU(cache)
-            // case "lfu":
-            //     simulator = lfu.NewLFU(cache)
-            case "arc":
-                simulator = arc.NewARC(cache)
-            case "larc":
-                simulator = larc.NewLARC(cache)
-            case "marc":
-                simulator = marc.NewMARC(cache)
-            default:
-                log.Fatal("Algorithm not supported")
-            }
-    
-            timeStart = time.Now()
-    
-            for _, trace := range traces {
-                err = simulator.Get(trace)
-                if err != nil {
-                    log.Fatal(err.Error())
-                }
-            }
-    
-            out.WriteString(
-                fmt.Sprintf("======== Algorithm: %4v ========\n", strings.ToUpper(algo)),
-            )
-            simulator.PrintToFile(out, timeStart)   
-            out.WriteString("\n\n")
-        }
+    err = runMatrix(jobs, traces, opts, workers, out)
+    if err != nil {
+        log.Fatal(err.Error())
     }
 
-
     fmt.Printf("Done")
 }
 
-func validateTraceSize(tracesize []string) (sizeList []int, err error) {
+// cacheSize is a cache size given either in pages ("1000") or relative to
+// the number of unique addresses of the trace ("1%", "0.1x")
+type cacheSize struct {
+    value       string
+    relative    float64 // fraction of the working set, zero for absolute sizes
+    size        int
+}
+
+func validateTraceSize(tracesize []string) (sizeList []cacheSize, err error) {
     var (
-        cacheList   []int
+        cacheList   []cacheSize
         cache       int
+        fraction    float64
     )
 
     for _, size := range tracesize {
-        if cache, err = strconv.Atoi(size); err != nil {
-            fmt.Println("Error: trace size must be an integer")
+        switch {
+        case strings.HasSuffix(size, "%"):
+            fraction, err = strconv.ParseFloat(strings.TrimSuffix(size, "%"), 64)
+            fraction /= 100
+        case strings.HasSuffix(strings.ToLower(size), "x"):
+            fraction, err = strconv.ParseFloat(size[:len(size) - 1], 64)
+        default:
+            if cache, err = strconv.Atoi(size); err != nil {
+                fmt.Println("Error: trace size must be an integer, a percentage (\"1%\") or a multiple (\"0.1x\") of the working set")
+                return sizeList, err
+            }
+            if cache < 1 {
+                fmt.Println("Error: trace size must be at least one page")
+                return sizeList, fmt.Errorf("invalid trace size %v", size)
+            }
+            cacheList = append(cacheList, cacheSize{value: size, size: cache})
+            continue
+        }
+
+        if err != nil || fraction <= 0 {
+            fmt.Println("Error: relative trace size must be a positive number")
+            if err == nil {
+                err = fmt.Errorf("invalid trace size %v", size)
+            }
             return sizeList, err
         }
-        cacheList = append(cacheList, cache)
+        cacheList = append(cacheList, cacheSize{value: size, relative: fraction})
     }
 
     return cacheList, nil
 }
 
-func readFile(filePath string) (traces []simulator.Trace, err error) {
-    var (
-        file    *os.File
-        scanner *bufio.Scanner
-        row     []string
-        address int
-    )
+// resolveTraceSize turns relative cache sizes into pages, counting the
+// unique addresses of the trace only when a relative size was given
+func resolveTraceSize(cacheList []cacheSize, traces []simulator.Trace) (unique int) {
+    unique = -1
 
-    file, err = os.Open(filePath)
-    if err != nil {
-        return traces, err
-    }
-    defer file.Close()
+    for i := range cacheList {
+        if cacheList[i].relative == 0 {
+            continue
+        }
 
-    scanner = bufio.NewScanner(file)
+        if unique < 0 {
+            unique = workingSetSize(traces)
+        }
 
-    for scanner.Scan() {
-        row = strings.Split(scanner.Text(), ",")
-        address, err = strconv.Atoi(row[0])
-        
-        if err != nil {
-            return traces, err
+        cacheList[i].size = int(math.Round(cacheList[i].relative * float64(unique)))
+        if cacheList[i].size < 1 {
+            cacheList[i].size = 1
         }
+    }
+
+    return unique
+}
+
+// workingSetSize counts the unique addresses of the trace
+func workingSetSize(traces []simulator.Trace) int {
+    seen := make(map[simulator.Key]struct{})
+    for _, trace := range traces {
+        seen[trace.Key] = struct{}{}
+    }
+
+    return len(seen)
+}
+
+func readFile(filePath string, format string, options reader.Options) (traces []simulator.Trace, err error) {
+    var r reader.Reader
+
+    r, err = reader.Open(filePath, format, options)
+    if err != nil {
+        return traces, err
+    }
+    defer r.Close()
 
-        traces = append(traces, simulator.Trace{
-            Addr:   address,
-            Op:     row[1],
-        })
+    traces, err = reader.ReadAll(r)
+    if skipped := reader.Skipped(r); skipped > 0 {
+        fmt.Printf("Skipped %d bad lines\n", skipped)
     }
 
-    return traces, nil
+    return traces, err
 }
 
 // func even(val int) (res bool, err error) {
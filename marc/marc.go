@@ -1,8 +1,9 @@
 package marc
 
 import (
+    "encoding/gob"
 	"fmt"
-	"os"
+	"io"
 	"time"
     "sort"
     // "math"
@@ -13,8 +14,8 @@ import (
 
 type (
     Node struct {
-        lba int
-        op  string
+        lba simulator.Key
+        op  simulator.Op
     }
 
     mARC struct {
@@ -24,6 +25,11 @@ type (
         miss        int
         p           int // p is the number of pages in T1; adaptation parameter
         wc          int
+        evict       int
+        deleted     int
+        onEvict     func(lba simulator.Key) // notified of every evicted page
+        purgeGhosts bool // delete requests also drop the page from B1/B2
+        ghost       *simulator.GhostStats
 
         state        string // state is the current state of the cache
         hitState     int // hrState is the hit rate of the current state
@@ -36,8 +42,52 @@ type (
         t2          *orderedmap.OrderedMap
         b1          *orderedmap.OrderedMap
         b2          *orderedmap.OrderedMap
-        filter      []int
+        filter      []simulator.Key
         filSize     int
+
+        requests    int
+        telemetry   []Telemetry
+    }
+
+    // state is what a snapshot saves of a mARC
+    state struct {
+        Maxlen          int
+        Hit             int
+        Miss            int
+        P               int
+        Wc              int
+        Evict           int
+        Deleted         int
+        Ghost           simulator.GhostStats
+
+        State           string
+        HitState        int
+        HitSample       int
+        HitSampleFil    int
+        Counter         int
+        FilCounter      int
+
+        T1              []simulator.Entry
+        T2              []simulator.Entry
+        B1              []simulator.Entry
+        B2              []simulator.Entry
+        Filter          []simulator.Key
+        FilSize         int
+
+        Requests        int
+        Telemetry       []Telemetry
+    }
+
+    // Telemetry is a snapshot of the adaptation state taken at the end of
+    // every sample window of maxlen requests.
+    Telemetry struct {
+        Request     int
+        State       string
+        HrState     float64
+        HrSample    float64
+        HrSampleFil float64
+        FilSize     int
+        P           int
     }
 )
 
@@ -49,6 +99,10 @@ func NewMARC(value int) *mARC {
         miss:           0,
         p:              0,
         wc:             0,
+        evict:          0,
+        deleted:        0,
+        purgeGhosts:    false,
+        ghost:          simulator.NewGhostStats(),
         state:          "unstable",
         hitState:       0,
         hitSample:      0,
@@ -59,16 +113,25 @@ func NewMARC(value int) *mARC {
         t2:             orderedmap.NewOrderedMap(),
         b1:             orderedmap.NewOrderedMap(),
         b2:             orderedmap.NewOrderedMap(),
-        filter:         make([]int, int(0.1 * float64(value))),
-        filSize:        int(0.1 * float64(value)),
+        filter:         make([]simulator.Key, int(0.1 * float64(value))),
+        filSize:        boundFilter(int(0.1 * float64(value)), value),
+        requests:       0,
+        telemetry:      make([]Telemetry, 0),
     }
 }
 
+// hit ratios of the current state, sample and filtered sample
+func (marc *mARC) hitRatios() (hrState, hrSample, hrSampleFil float64) {
+    hrState = float64(marc.hitState) / float64(marc.counter)
+    hrSample = float64(marc.hitSample) / float64(marc.maxlen)
+    hrSampleFil = float64(marc.hitSampleFil) / float64(marc.filCounter)
+
+    return hrState, hrSample, hrSampleFil
+}
+
 // state changer for mARC
 func (marc *mARC) StateChange() (reset bool) {
-    hrState := float64(marc.hitState) / float64(marc.counter)
-    hrSample := float64(marc.hitSample) / float64(marc.maxlen)
-    hrSampleFil := float64(marc.hitSampleFil) / float64(marc.filCounter)
+    hrState, hrSample, hrSampleFil := marc.hitRatios()
 
     // fmt.Println(marc.state, marc.hitState, marc.hitSample, marc.hitSampleFil, marc.counter, marc.filCounter)
 
@@ -94,9 +157,29 @@ func (marc *mARC) StateChange() (reset bool) {
     return false
 }
 
-func getIndex(slice []int, target int) (index int, ok bool) {
+// boundFilter keeps the size of the filter between 10% and 90% of the
+// cache, and at least a page so that tiny caches can adapt too
+func boundFilter(filSize int, maxlen int) int {
+    low, high := int(0.1 * float64(maxlen)), int(0.9 * float64(maxlen))
+    if low < 1 {
+        low = 1
+    }
+    if high < low {
+        high = low
+    }
+
+    if filSize < low {
+        return low
+    }
+    if filSize > high {
+        return high
+    }
+    return filSize
+}
+
+func getIndex(slice []simulator.Key, target simulator.Key) (index int, ok bool) {
     index = sort.Search(len(slice), func(i int) bool {
-        return slice[i] >= target
+        return !slice[i].Less(target)
     })
 
     if index < len(slice) && slice[index] == target {
@@ -128,8 +211,10 @@ func (marc *mARC) Filter(data *Node) (exists bool) {
 
 
 func (marc *mARC) Replace(data *Node) (err error) {
+    key := data.lba.MapKey()
+
     t1size := marc.t1.Len()
-    _, b2exist := marc.b2.Get(data.lba)
+    _, b2exist := marc.b2.Get(key)
     if t1size > 0 && (t1size > marc.p || (b2exist && t1size == marc.p)) {
         // move LRU of T1 to MRU of B1
         lruKey, lruVal, ok := marc.t1.GetFirst()
@@ -138,6 +223,8 @@ func (marc *mARC) Replace(data *Node) (err error) {
         }
         marc.t1.Delete(lruKey)
         marc.b1.Set(lruKey, lruVal)
+        marc.evicted(lruKey)
+        marc.ghost.T1Evictions++
     } else {
         // move LRU of T2 to MRU of B2
         lruKey, lruVal, ok := marc.t2.GetFirst()
@@ -146,11 +233,15 @@ func (marc *mARC) Replace(data *Node) (err error) {
         }
         marc.t2.Delete(lruKey)
         marc.b2.Set(lruKey, lruVal)
+        marc.evicted(lruKey)
+        marc.ghost.T2Evictions++
     }
     return nil
 }
 
 func (marc *mARC) Put(data *Node) (exists bool) {
+    key := data.lba.MapKey()
+
     // length of list
     t1size := marc.t1.Len()
     t2size := marc.t2.Len()
@@ -160,18 +251,19 @@ func (marc *mARC) Put(data *Node) (exists bool) {
     marc.counter++
 
     // first case: data is in T1 or T2
-    if _, ok := marc.t1.Get(data.lba); ok {
-        marc.t1.Delete(data.lba)
-        marc.t2.Set(data.lba, data.op)
+    if _, ok := marc.t1.Get(key); ok {
+        marc.t1.Delete(key)
+        marc.t2.Set(key, data.op)
         marc.hit++
+        marc.ghost.Promotions++
         marc.hitState++
         marc.hitSample++
         
         // resize the filter
-        marc.filSize = marc.filSize - marc.maxlen / (marc.maxlen - marc.filSize)
-        if float64(marc.filSize) < 0.1 * float64(marc.maxlen) {
-            marc.filSize = int(0.1 * float64(marc.maxlen))
+        if marc.maxlen > marc.filSize {
+            marc.filSize = marc.filSize - marc.maxlen / (marc.maxlen - marc.filSize)
         }
+        marc.filSize = boundFilter(marc.filSize, marc.maxlen)
         size := marc.filSize
         if size > len(marc.filter) {
             size = len(marc.filter)
@@ -179,17 +271,17 @@ func (marc *mARC) Put(data *Node) (exists bool) {
         marc.filter = marc.filter[len(marc.filter) - size:]
 
         return true
-    } else if _, ok := marc.t2.Get(data.lba); ok {
-        marc.t2.MoveLast(data.lba)
+    } else if _, ok := marc.t2.Get(key); ok {
+        marc.t2.MoveLast(key)
         marc.hit++
         marc.hitState++
         marc.hitSample++
 
         // resize the filter
-        marc.filSize = marc.filSize - marc.maxlen / (marc.maxlen - marc.filSize)
-        if float64(marc.filSize) < 0.1 * float64(marc.maxlen) {
-            marc.filSize = int(0.1 * float64(marc.maxlen))
+        if marc.maxlen > marc.filSize {
+            marc.filSize = marc.filSize - marc.maxlen / (marc.maxlen - marc.filSize)
         }
+        marc.filSize = boundFilter(marc.filSize, marc.maxlen)
         size := marc.filSize
         if size > len(marc.filter) {
             size = len(marc.filter)
@@ -202,10 +294,7 @@ func (marc *mARC) Put(data *Node) (exists bool) {
     // filter data when "stable" or "unique-access"
     if marc.state != "unstable" {
         // resize the filter
-        marc.filSize = marc.filSize + (marc.maxlen / marc.filSize)
-        if float64(marc.filSize) > 0.9 * float64(marc.maxlen) {
-            marc.filSize = int(0.9 * float64(marc.maxlen))
-        }
+        marc.filSize = boundFilter(marc.filSize + (marc.maxlen / marc.filSize), marc.maxlen)
         size := marc.filSize
         if size > len(marc.filter) {
             size = len(marc.filter)
@@ -221,7 +310,9 @@ func (marc *mARC) Put(data *Node) (exists bool) {
     marc.wc++
 
     // second case: data is in B1
-    if _, ok := marc.b1.Get(data.lba); ok {
+    if _, ok := marc.b1.Get(key); ok {
+        marc.ghost.B1Hits++
+
         // adaptation
         delta := 1
         if b1size < b2size {
@@ -241,15 +332,17 @@ func (marc *mARC) Put(data *Node) (exists bool) {
         }
 
         // move data from B1 to T2
-        marc.b1.Delete(data.lba)
-        marc.t2.Set(data.lba, data.op)
+        marc.b1.Delete(key)
+        marc.t2.Set(key, data.op)
 
         marc.miss++
         return false
     }
 
     // third case: data is in B2
-    if _, ok := marc.b2.Get(data.lba); ok {
+    if _, ok := marc.b2.Get(key); ok {
+        marc.ghost.B2Hits++
+
         // adaptation
         delta := 1
         if b2size < b1size {
@@ -269,8 +362,8 @@ func (marc *mARC) Put(data *Node) (exists bool) {
         }
 
         // move data from B2 to T2
-        marc.b2.Delete(data.lba)
-        marc.t2.Set(data.lba, data.op)
+        marc.b2.Delete(key)
+        marc.t2.Set(key, data.op)
 
         marc.miss++
         return false
@@ -286,6 +379,8 @@ func (marc *mARC) Put(data *Node) (exists bool) {
             // B1 is empty
             key, _, _ := marc.t1.GetFirst()
             marc.t1.Delete(key)
+            marc.evicted(key)
+            marc.ghost.T1Evictions++
         }
     }
     // * second case: T1 and B1 has less than c pages
@@ -304,20 +399,143 @@ func (marc *mARC) Put(data *Node) (exists bool) {
     }
 
     marc.miss++
-    marc.t1.Set(data.lba, data.op)
+    marc.t1.Set(key, data.op)
 
     return false
 }
 
+// SetPurgeGhosts makes delete requests also drop the page from the ghost
+// lists, so a later request for it is not taken as a ghost hit
+func (marc *mARC) SetPurgeGhosts(purge bool) {
+    marc.purgeGhosts = purge
+}
+
+// Remove drops lba from T1 or T2 and from the filter, returning whether it was cached
+func (marc *mARC) Remove(lba simulator.Key) (removed bool) {
+    key := lba.MapKey()
+
+    for i, candidate := range marc.filter {
+        if candidate == lba {
+            marc.filter = append(marc.filter[:i], marc.filter[i + 1:]...)
+            break
+        }
+    }
+
+    if _, ok := marc.t1.Get(key); ok {
+        marc.t1.Delete(key)
+        removed = true
+    } else if _, ok := marc.t2.Get(key); ok {
+        marc.t2.Delete(key)
+        removed = true
+    }
+
+    if marc.purgeGhosts {
+        marc.b1.Delete(key)
+        marc.b2.Delete(key)
+    }
+
+    return removed
+}
+
+// Contains reports whether lba is cached, without updating the lists
+func (marc *mARC) Contains(lba simulator.Key) bool {
+    key := lba.MapKey()
+
+    if _, ok := marc.t1.Get(key); ok {
+        return true
+    }
+    _, ok := marc.t2.Get(key)
+    return ok
+}
+
+// Keys returns the cached pages of T1 then T2, each from the least to the
+// most recently used
+func (marc *mARC) Keys() (keys []simulator.Key) {
+    for _, list := range []*orderedmap.OrderedMap{marc.t1, marc.t2} {
+        iter := list.Iter()
+        for key, _, ok := iter.Next(); ok; key, _, ok = iter.Next() {
+            keys = append(keys, simulator.KeyOf(key))
+        }
+    }
+    return keys
+}
+
+// OnEvict registers fn to be called with every page evicted from the cache
+func (marc *mARC) OnEvict(fn func(lba simulator.Key)) {
+    marc.onEvict = fn
+}
+
+func (marc *mARC) evicted(key interface{}) {
+    // an empty list, as in a cache of no pages, has nothing to evict
+    if key == nil {
+        return
+    }
+    marc.evict++
+    if marc.onEvict != nil {
+        marc.onEvict(simulator.KeyOf(key))
+    }
+}
+
+// Resize changes the capacity of the cache. When it shrinks, pages are
+// replaced into the ghost lists as REPLACE would, and the ghost lists are
+// trimmed to the bounds of the new size.
+func (marc *mARC) Resize(value int) {
+    marc.maxlen = value
+    if marc.p > value {
+        marc.p = value
+    }
+
+    for marc.t1.Len() + marc.t2.Len() > value {
+        if marc.t1.Len() > 0 && (marc.t1.Len() > marc.p || marc.t2.Len() == 0) {
+            lruKey, lruVal, _ := marc.t1.PopFirst()
+            marc.b1.Set(lruKey, lruVal)
+            marc.evicted(lruKey)
+            marc.ghost.T1Evictions++
+        } else {
+            lruKey, lruVal, _ := marc.t2.PopFirst()
+            marc.b2.Set(lruKey, lruVal)
+            marc.evicted(lruKey)
+            marc.ghost.T2Evictions++
+        }
+    }
+
+    for marc.t1.Len() + marc.b1.Len() > value && marc.b1.Len() > 0 {
+        marc.b1.PopFirst()
+    }
+    for marc.t1.Len() + marc.t2.Len() + marc.b1.Len() + marc.b2.Len() > 2 * value && marc.b2.Len() > 0 {
+        marc.b2.PopFirst()
+    }
+
+    // keep the filter within its bounds for the new size
+    marc.filSize = boundFilter(marc.filSize, value)
+    if len(marc.filter) > marc.filSize {
+        marc.filter = marc.filter[len(marc.filter) - marc.filSize:]
+    }
+}
+
 func (marc *mARC) Get(trace simulator.Trace) (err error) {
+    if trace.Op == simulator.OpDelete {
+        if marc.Remove(trace.Key) {
+            marc.deleted++
+        }
+        return nil
+    }
+
     obj := new(Node)
-    obj.lba = trace.Addr
+    obj.lba = trace.Key
     obj.op = trace.Op
     marc.Put(obj)
+    marc.requests++
+    marc.ghost.ObserveP(marc.p, marc.maxlen)
 
     // state changer
     if marc.counter % marc.maxlen == 0 && marc.counter != 0 {
         // fmt.Println(marc.state)
+        hrState, hrSample, hrSampleFil := marc.hitRatios()
+        if marc.filCounter == 0 {
+            hrSampleFil = 0
+        }
+
         if marc.counter >= marc.maxlen * 2 {
             if marc.StateChange() {
                 marc.counter = 0
@@ -325,6 +543,16 @@ func (marc *mARC) Get(trace simulator.Trace) (err error) {
             }
         }
 
+        marc.telemetry = append(marc.telemetry, Telemetry{
+            Request:        marc.requests,
+            State:          marc.state,
+            HrState:        hrState,
+            HrSample:       hrSample,
+            HrSampleFil:    hrSampleFil,
+            FilSize:        marc.filSize,
+            P:              marc.p,
+        })
+
         marc.hitSample = 0
         marc.hitSampleFil = 0
         marc.filCounter = 0
@@ -332,13 +560,126 @@ func (marc *mARC) Get(trace simulator.Trace) (err error) {
     return nil
 }
 
-func (marc *mARC) PrintToFile(file *os.File, start time.Time) (err error) {
-    file.WriteString(fmt.Sprintf("cache size: %d\n", marc.maxlen))
-    file.WriteString(fmt.Sprintf("cache hit: %d\n", marc.hit))
-    file.WriteString(fmt.Sprintf("cache miss: %d\n", marc.miss))
-    file.WriteString(fmt.Sprintf("cache hit ratio: %.4f%%\n", float64(marc.hit) / float64(marc.hit + marc.miss) * 100))
-    file.WriteString(fmt.Sprintf("cache write count: %d\n", marc.wc))
-    file.WriteString(fmt.Sprintf("time execution: %8.4f\n", time.Since(start).Seconds()))
+func (marc *mARC) Stats() simulator.Stats {
+    return simulator.Stats{
+        Hit:        marc.hit,
+        Miss:       marc.miss,
+        Write:      marc.wc,
+        Eviction:   marc.evict,
+        Delete:     marc.deleted,
+        Occupancy:  marc.t1.Len() + marc.t2.Len(),
+        Capacity:   marc.maxlen,
+    }
+}
+
+func (marc *mARC) PrintToFile(file io.Writer, elapsed time.Duration) (err error) {
+    fmt.Fprintf(file, "cache size: %d\n", marc.maxlen)
+    fmt.Fprintf(file, "cache hit: %d\n", marc.hit)
+    fmt.Fprintf(file, "cache miss: %d\n", marc.miss)
+    fmt.Fprintf(file, "cache hit ratio: %.4f%%\n", float64(marc.hit) / float64(marc.hit + marc.miss) * 100)
+    fmt.Fprintf(file, "cache write count: %d\n", marc.wc)
+    fmt.Fprintf(file, "delete count: %d\n", marc.deleted)
+    ghost := marc.GhostStats()
+    ghost.PrintToFile(file)
+    fmt.Fprintf(file, "time execution: %8.4f\n", elapsed.Seconds())
+
+    return nil
+}
+
+// GhostStats returns the ghost list counters and the current list sizes
+func (marc *mARC) GhostStats() simulator.GhostStats {
+    ghost := *marc.ghost
+    ghost.PHistogram = append([]int(nil), marc.ghost.PHistogram...)
+    ghost.P = marc.p
+    ghost.T1 = marc.t1.Len()
+    ghost.T2 = marc.t2.Len()
+    ghost.B1 = marc.b1.Len()
+    ghost.B2 = marc.b2.Len()
+
+    return ghost
+}
+
+// Details returns the ghost list analytics for the results file
+func (marc *mARC) Details() interface{} {
+    return marc.GhostStats()
+}
+
+// Telemetry returns the adaptation snapshots recorded so far
+func (marc *mARC) Telemetry() []Telemetry {
+    return marc.telemetry
+}
+
+func (marc *mARC) PrintTelemetryToFile(file io.Writer) (err error) {
+    io.WriteString(file, "request,state,hr state,hr sample,hr sample fil,filter size,p\n")
+    for _, t := range marc.telemetry {
+        fmt.Fprintf(
+            file, "%d,%s,%.4f,%.4f,%.4f,%d,%d\n",
+            t.Request, t.State, t.HrState, t.HrSample, t.HrSampleFil, t.FilSize, t.P,
+        )
+    }
 
     return nil
-}
\ No newline at end of file
+}
+
+// Checkpoint saves the lists, the filter, the adaptation state, the
+// counters and the telemetry
+func (marc *mARC) Checkpoint(enc *gob.Encoder) (err error) {
+    return enc.Encode(state{
+        Maxlen:         marc.maxlen,
+        Hit:            marc.hit,
+        Miss:           marc.miss,
+        P:              marc.p,
+        Wc:             marc.wc,
+        Evict:          marc.evict,
+        Deleted:        marc.deleted,
+        Ghost:          *marc.ghost,
+        State:          marc.state,
+        HitState:       marc.hitState,
+        HitSample:      marc.hitSample,
+        HitSampleFil:   marc.hitSampleFil,
+        Counter:        marc.counter,
+        FilCounter:     marc.filCounter,
+        T1:             simulator.SaveList(marc.t1),
+        T2:             simulator.SaveList(marc.t2),
+        B1:             simulator.SaveList(marc.b1),
+        B2:             simulator.SaveList(marc.b2),
+        Filter:         marc.filter,
+        FilSize:        marc.filSize,
+        Requests:       marc.requests,
+        Telemetry:      marc.telemetry,
+    })
+}
+
+// Restore replaces the state of the cache by one saved by Checkpoint
+func (marc *mARC) Restore(dec *gob.Decoder) (err error) {
+    var s state
+
+    if err = dec.Decode(&s); err != nil {
+        return err
+    }
+
+    marc.maxlen = s.Maxlen
+    marc.hit = s.Hit
+    marc.miss = s.Miss
+    marc.p = s.P
+    marc.wc = s.Wc
+    marc.evict = s.Evict
+    marc.deleted = s.Deleted
+    marc.ghost = &s.Ghost
+    marc.state = s.State
+    marc.hitState = s.HitState
+    marc.hitSample = s.HitSample
+    marc.hitSampleFil = s.HitSampleFil
+    marc.counter = s.Counter
+    marc.filCounter = s.FilCounter
+    marc.t1 = simulator.LoadList(s.T1)
+    marc.t2 = simulator.LoadList(s.T2)
+    marc.b1 = simulator.LoadList(s.B1)
+    marc.b2 = simulator.LoadList(s.B2)
+    marc.filter = append(make([]simulator.Key, 0, len(s.Filter)), s.Filter...)
+    marc.filSize = s.FilSize
+    marc.requests = s.Requests
+    marc.telemetry = append(make([]Telemetry, 0, len(s.Telemetry)), s.Telemetry...)
+
+    return nil
+}
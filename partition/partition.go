@@ -0,0 +1,361 @@
+package partition
+
+import (
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/mohammadtauchid/golang-cache/v2/analyzer"
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// Mode is how the cache is shared between the tenants
+type Mode int
+
+const (
+    // Shared serves every tenant from a single unpartitioned cache
+    Shared Mode = iota
+    // Static gives every tenant a fixed share of the cache
+    Static
+    // Dynamic repartitions the cache periodically by the utility of every
+    // tenant, as estimated from its miss ratio curve (UCP)
+    Dynamic
+)
+
+// Modes lists the names accepted by Parse
+var Modes = []string{"shared", "static", "ucp"}
+
+func (mode Mode) String() string {
+    if int(mode) < len(Modes) {
+        return Modes[mode]
+    }
+    return fmt.Sprintf("Mode(%d)", int(mode))
+}
+
+type (
+    // Config is a parsed partitioning option
+    Config struct {
+        Mode        Mode
+        Weights     []float64   // static shares, in tenant order; equal when empty
+        Interval    int         // requests between two repartitionings
+    }
+
+    // Factory creates the policy of a partition holding size pages
+    Factory func(size int) (simulator.Policy, error)
+
+    // tenant holds the partition of a tenant and its utility monitor
+    tenant struct {
+        id          int
+        policy      simulator.Policy
+        size        int
+
+        stack       *analyzer.StackDistance // utility monitor, dynamic mode
+        utility     []float64               // hits per allocation unit, decayed
+    }
+
+    // Partitioned serves the requests of several tenants from one cache of
+    // a fixed capacity, partitioned between them or shared
+    Partitioned struct {
+        config      Config
+        capacity    int
+        unit        int // pages allocated at a time by the dynamic mode
+
+        shared      simulator.Policy
+        tenants     map[int]*tenant
+        order       []*tenant
+
+        stats       *simulator.TenantStats
+        requests    int
+        repartitions int
+    }
+)
+
+// Parse reads a partitioning option: "shared", "static" with optional
+// comma-separated weights ("static:2,1,1"), or "ucp" with an optional
+// repartitioning interval in requests ("ucp:100000")
+func Parse(spec string) (config Config, err error) {
+    name, value, hasValue := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ":")
+
+    switch name {
+    case "shared":
+        config.Mode = Shared
+    case "static":
+        config.Mode = Static
+        if hasValue {
+            for _, field := range strings.Split(value, ",") {
+                weight, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+                if err != nil || weight <= 0 {
+                    return config, fmt.Errorf("invalid partition weight %q", field)
+                }
+                config.Weights = append(config.Weights, weight)
+            }
+        }
+    case "ucp", "dynamic":
+        config.Mode = Dynamic
+        config.Interval = 100000
+        if hasValue {
+            if config.Interval, err = strconv.Atoi(value); err != nil || config.Interval < 1 {
+                return config, fmt.Errorf("invalid repartitioning interval %q", value)
+            }
+        }
+    default:
+        return config, fmt.Errorf("unknown partitioning %q, expected one of %v", name, Modes)
+    }
+
+    return config, nil
+}
+
+// NewPartitioned creates a cache of capacity pages for the given tenants.
+// Partitions are created by factory; the dynamic mode needs policies
+// implementing simulator.Resizer.
+func NewPartitioned(config Config, capacity int, tenants []int, factory Factory) (p *Partitioned, err error) {
+    if len(tenants) == 0 {
+        return nil, fmt.Errorf("no tenants to partition the cache between")
+    }
+    if config.Mode != Shared && capacity < len(tenants) {
+        return nil, fmt.Errorf("cache of %d pages too small for %d tenants", capacity, len(tenants))
+    }
+    if len(config.Weights) > 0 && len(config.Weights) != len(tenants) {
+        return nil, fmt.Errorf("%d partition weights for %d tenants", len(config.Weights), len(tenants))
+    }
+
+    p = &Partitioned{
+        config:     config,
+        capacity:   capacity,
+        unit:       capacity / 64,
+        tenants:    make(map[int]*tenant),
+        stats:      simulator.NewTenantStats(),
+    }
+    if p.unit < 1 || p.unit * len(tenants) > capacity {
+        p.unit = 1
+    }
+
+    for _, id := range tenants {
+        t := &tenant{id: id}
+        p.tenants[id] = t
+        p.order = append(p.order, t)
+    }
+
+    if config.Mode == Shared {
+        p.shared, err = factory(capacity)
+        return p, err
+    }
+
+    for i, size := range p.split(config.Weights) {
+        t := p.order[i]
+        t.size = size
+        if t.policy, err = factory(size); err != nil {
+            return nil, err
+        }
+
+        if config.Mode == Dynamic {
+            if _, ok := t.policy.(simulator.Resizer); !ok {
+                return nil, fmt.Errorf("dynamic partitioning needs resizable policies")
+            }
+            t.stack = analyzer.NewStackDistance()
+            t.utility = make([]float64, capacity / p.unit + 1)
+        }
+    }
+
+    return p, nil
+}
+
+// split divides the capacity by the weights, equally without weights,
+// giving every tenant at least a page
+func (p *Partitioned) split(weights []float64) (sizes []int) {
+    var total, given float64
+
+    if len(weights) == 0 {
+        for range p.order {
+            weights = append(weights, 1)
+        }
+    }
+    for _, weight := range weights {
+        total += weight
+    }
+
+    sizes = make([]int, len(weights))
+    assigned := 0
+    for i, weight := range weights {
+        given += weight
+        // cumulative rounding keeps the sizes summing to the capacity
+        sizes[i] = int(given / total * float64(p.capacity)) - assigned
+        assigned += sizes[i]
+    }
+
+    // a tenant rounded down to nothing takes a page from the largest share;
+    // there is at least a page per tenant
+    for i := range sizes {
+        for sizes[i] < 1 {
+            largest := 0
+            for j := range sizes {
+                if sizes[j] > sizes[largest] {
+                    largest = j
+                }
+            }
+            sizes[largest]--
+            sizes[i]++
+        }
+    }
+
+    return sizes
+}
+
+func (p *Partitioned) Get(trace simulator.Trace) (err error) {
+    t, ok := p.tenants[trace.Tenant]
+    if !ok {
+        return fmt.Errorf("request of unknown tenant %d", trace.Tenant)
+    }
+
+    policy := t.policy
+    if p.config.Mode == Shared {
+        policy = p.shared
+    }
+
+    before := policy.Stats()
+    if err = policy.Get(trace); err != nil {
+        return err
+    }
+    p.stats.Observe(trace, before, policy.Stats())
+
+    if trace.Op == simulator.OpDelete {
+        return nil
+    }
+    p.requests++
+
+    if p.config.Mode == Dynamic {
+        if distance, ok := t.stack.Access(trace.Key); ok && distance / p.unit < len(t.utility) {
+            t.utility[distance / p.unit]++
+        }
+        if p.requests % p.config.Interval == 0 {
+            p.repartition()
+        }
+    }
+
+    return nil
+}
+
+// repartition allocates the capacity unit by unit with the lookahead
+// algorithm of utility-based cache partitioning: every round, the tenant
+// with the most extra hits per unit over any number of additional units
+// receives those units
+func (p *Partitioned) repartition() {
+    var (
+        units       int = p.capacity / p.unit
+        allocation  []int = make([]int, len(p.order))
+    )
+
+    for i := range allocation {
+        allocation[i] = 1
+    }
+    units -= len(p.order)
+
+    for units > 0 {
+        best, bestUnits, bestUtility := -1, 0, -1.0
+
+        for i, t := range p.order {
+            gain := 0.0
+            for k := 1; k <= units && allocation[i] + k - 1 < len(t.utility); k++ {
+                gain += t.utility[allocation[i] + k - 1]
+                if perUnit := gain / float64(k); perUnit > bestUtility {
+                    best, bestUnits, bestUtility = i, k, perUnit
+                }
+            }
+        }
+
+        if best < 0 {
+            break
+        }
+        allocation[best] += bestUnits
+        units -= bestUnits
+    }
+    // units no tenant can use, e.g. past the end of every curve
+    allocation[0] += units
+
+    assigned := 0
+    for i, t := range p.order {
+        size := allocation[i] * p.unit
+        if i == len(p.order) - 1 {
+            size = p.capacity - assigned
+        }
+        assigned += size
+
+        if size != t.size {
+            t.policy.(simulator.Resizer).Resize(size)
+            t.size = size
+        }
+
+        // halve the history, so the curves follow changes of the workload
+        for u := range t.utility {
+            t.utility[u] /= 2
+        }
+    }
+
+    p.repartitions++
+}
+
+func (p *Partitioned) policies() (policies []simulator.Policy) {
+    if p.shared != nil {
+        return []simulator.Policy{p.shared}
+    }
+    for _, t := range p.order {
+        policies = append(policies, t.policy)
+    }
+    return policies
+}
+
+func (p *Partitioned) Stats() (stats simulator.Stats) {
+    for _, policy := range p.policies() {
+        policyStats := policy.Stats()
+        stats.Hit += policyStats.Hit
+        stats.Miss += policyStats.Miss
+        stats.Write += policyStats.Write
+        stats.Eviction += policyStats.Eviction
+        stats.Delete += policyStats.Delete
+        stats.Occupancy += policyStats.Occupancy
+    }
+    stats.Capacity = p.capacity
+
+    return stats
+}
+
+// SetPurgeGhosts forwards the option to policies keeping ghost lists
+func (p *Partitioned) SetPurgeGhosts(purge bool) {
+    for _, policy := range p.policies() {
+        if purger, ok := policy.(interface{ SetPurgeGhosts(bool) }); ok {
+            purger.SetPurgeGhosts(purge)
+        }
+    }
+}
+
+// TenantStats returns the counters of every tenant
+func (p *Partitioned) TenantStats() *simulator.TenantStats {
+    return p.stats
+}
+
+func (p *Partitioned) PrintToFile(file io.Writer, elapsed time.Duration) (err error) {
+    stats := p.Stats()
+
+    fmt.Fprintf(file, "cache size: %d\n", p.capacity)
+    fmt.Fprintf(file, "partitioning: %v\n", p.config.Mode)
+    fmt.Fprintf(file, "cache hit: %d\n", stats.Hit)
+    fmt.Fprintf(file, "cache miss: %d\n", stats.Miss)
+    fmt.Fprintf(file, "cache hit ratio: %.4f%%\n", float64(stats.Hit) / float64(stats.Hit + stats.Miss) * 100)
+    fmt.Fprintf(file, "write count: %d\n", stats.Write)
+    fmt.Fprintf(file, "delete count: %d\n", stats.Delete)
+    if p.config.Mode == Dynamic {
+        fmt.Fprintf(file, "repartitions: %d\n", p.repartitions)
+    }
+
+    if p.config.Mode != Shared {
+        for _, t := range p.order {
+            fmt.Fprintf(file, "tenant %d partition size: %d\n", t.id, t.size)
+        }
+    }
+    p.stats.PrintToFile(file)
+    fmt.Fprintf(file, "time execution: %8.4f\n", elapsed.Seconds())
+
+    return nil
+}
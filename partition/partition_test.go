@@ -0,0 +1,114 @@
+package partition
+
+import (
+    "math/rand"
+    "testing"
+
+    "github.com/mohammadtauchid/golang-cache/v2/larc"
+    "github.com/mohammadtauchid/golang-cache/v2/lru"
+    "github.com/mohammadtauchid/golang-cache/v2/marc"
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+var factories = map[string]Factory{
+    "larc": func(size int) (simulator.Policy, error) { return larc.NewLARC(size), nil },
+    "marc": func(size int) (simulator.Policy, error) { return marc.NewMARC(size), nil },
+}
+
+// requests draws keys from a small working set per tenant, so that the
+// filters admit keys and the partitions adapt
+func requests(n int, tenants []int) (traces []simulator.Trace) {
+    random := rand.New(rand.NewSource(1))
+    for i := 0; i < n; i++ {
+        tenant := tenants[random.Intn(len(tenants))]
+        traces = append(traces, simulator.Trace{
+            Key:    simulator.Key{Hi: uint64(tenant), Lo: uint64(random.Intn(50))},
+            Op:     simulator.OpRead,
+            Tenant: tenant,
+        })
+    }
+    return traces
+}
+
+// TestTinyPartitions serves partitions of fewer than ten pages, whose
+// adaptive queues used to round down to zero pages
+func TestTinyPartitions(t *testing.T) {
+    tenants := []int{1, 2, 3}
+    configs := map[string]struct {
+        config      Config
+        capacity    int
+    }{
+        "static":   {Config{Mode: Static, Weights: []float64{10, 1, 1}}, 60},
+        "ucp":      {Config{Mode: Dynamic, Interval: 500}, 30},
+    }
+
+    for name, c := range configs {
+        for algorithm, factory := range factories {
+            p, err := NewPartitioned(c.config, c.capacity, tenants, factory)
+            if err != nil {
+                t.Fatalf("%v %v: %v", name, algorithm, err)
+            }
+            for _, trace := range requests(20000, tenants) {
+                if err = p.Get(trace); err != nil {
+                    t.Fatalf("%v %v: %v", name, algorithm, err)
+                }
+            }
+
+            stats := p.Stats()
+            if stats.Hit + stats.Miss != 20000 {
+                t.Errorf("%v %v: %d requests served, expected 20000", name, algorithm, stats.Hit + stats.Miss)
+            }
+            if stats.Occupancy > c.capacity {
+                t.Errorf("%v %v: %d pages cached in %d", name, algorithm, stats.Occupancy, c.capacity)
+            }
+        }
+    }
+}
+
+func TestSplit(t *testing.T) {
+    tests := []struct {
+        capacity    int
+        weights     []float64
+        sizes       []int
+    }{
+        {3, []float64{10, 1, 1}, []int{1, 1, 1}},
+        {4, []float64{10, 1, 1}, []int{2, 1, 1}},
+        {5, []float64{1, 1, 10}, []int{1, 1, 3}},
+        {3, nil, []int{1, 1, 1}},
+        {7, nil, []int{2, 2, 3}},
+        {12, []float64{2, 1, 1}, []int{6, 3, 3}},
+        {60, []float64{10, 1, 1}, []int{50, 5, 5}},
+        {4, []float64{100, 1, 1, 1}, []int{1, 1, 1, 1}},
+    }
+
+    factory := func(size int) (simulator.Policy, error) { return lru.NewLRU(size), nil }
+    for _, test := range tests {
+        var tenants []int
+        for i := range test.sizes {
+            tenants = append(tenants, i)
+        }
+
+        p, err := NewPartitioned(Config{Mode: Static, Weights: test.weights}, test.capacity, tenants, factory)
+        if err != nil {
+            t.Fatalf("%d pages, weights %v: %v", test.capacity, test.weights, err)
+        }
+
+        total := 0
+        for i, tenant := range p.order {
+            total += tenant.size
+            if tenant.size != test.sizes[i] {
+                t.Errorf("%d pages, weights %v: tenant %d got %d pages, expected %d", test.capacity, test.weights, i, tenant.size, test.sizes[i])
+            }
+            if tenant.policy.Stats().Capacity != tenant.size {
+                t.Errorf("%d pages, weights %v: tenant %d has a policy of %d pages", test.capacity, test.weights, i, tenant.policy.Stats().Capacity)
+            }
+        }
+        if total != test.capacity {
+            t.Errorf("%d pages, weights %v: %d pages given", test.capacity, test.weights, total)
+        }
+    }
+
+    if _, err := NewPartitioned(Config{Mode: Static}, 2, []int{1, 2, 3}, factory); err == nil {
+        t.Error("split 2 pages between 3 tenants")
+    }
+}
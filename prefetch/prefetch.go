@@ -0,0 +1,232 @@
+package prefetch
+
+import (
+    "fmt"
+    "io"
+    "time"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+type (
+    // Prefetcher decides which pages to read ahead of the demand requests
+    Prefetcher interface {
+        // Access is called for every demand read or write, with whether it
+        // hit the cache, and returns the pages to prefetch
+        Access(key simulator.Key, hit bool) []simulator.Key
+    }
+
+    // Feedback is implemented by prefetchers that adapt to the fate of
+    // the pages they prefetched
+    Feedback interface {
+        Used(key simulator.Key)     // a prefetched page was requested
+        Wasted(key simulator.Key)   // a prefetched page was evicted unused
+        Dropped(key simulator.Key)  // a page was not prefetched, or was removed
+    }
+
+    // Cache places a prefetcher in front of a policy. Prefetched pages are
+    // inserted into the policy like any other page; hits on them, and the
+    // writes and evictions they cause, are counted apart from demand ones.
+    Cache struct {
+        policy      simulator.Policy
+        prefetcher  Prefetcher
+        feedback    Feedback
+
+        hit         int // demand hits on pages the policy cached on demand
+        miss        int
+        wc          int // writes caused by demand requests
+        deleted     int
+
+        prefetchHit     int // demand hits on prefetched pages
+        issued          int // prefetches sent to the policy
+        wasted          int // prefetched pages evicted before any request
+        prefetchWrite   int // writes caused by prefetches
+
+        prefetched  map[simulator.Key]struct{} // cached pages not requested since prefetched
+        onEvict     func(lba simulator.Key)
+    }
+
+    // telemetryCache is a Cache around a policy recording telemetry
+    telemetryCache struct {
+        *Cache
+    }
+)
+
+// NewCache places prefetcher in front of policy; the cache records the
+// telemetry of the policy when the policy does
+func NewCache(policy simulator.Policy, prefetcher Prefetcher) simulator.Policy {
+    c := &Cache{
+        policy:     policy,
+        prefetcher: prefetcher,
+        prefetched: make(map[simulator.Key]struct{}),
+    }
+
+    if feedback, ok := prefetcher.(Feedback); ok {
+        c.feedback = feedback
+    }
+    policy.OnEvict(c.evicted)
+
+    if _, ok := policy.(simulator.Telemetry); ok {
+        return &telemetryCache{c}
+    }
+    return c
+}
+
+func (c *Cache) evicted(lba simulator.Key) {
+    if _, ok := c.prefetched[lba]; ok {
+        delete(c.prefetched, lba)
+        c.wasted++
+        if c.feedback != nil {
+            c.feedback.Wasted(lba)
+        }
+    }
+
+    if c.onEvict != nil {
+        c.onEvict(lba)
+    }
+}
+
+func (c *Cache) Get(trace simulator.Trace) (err error) {
+    if trace.Op == simulator.OpDelete {
+        if c.Remove(trace.Key) {
+            c.deleted++
+        }
+        return nil
+    }
+
+    _, wasPrefetched := c.prefetched[trace.Key]
+    before := c.policy.Stats()
+
+    if err = c.policy.Get(trace); err != nil {
+        return err
+    }
+
+    after := c.policy.Stats()
+    hit := after.Hit > before.Hit
+    c.wc += after.Write - before.Write
+
+    switch {
+    case hit && wasPrefetched:
+        c.prefetchHit++
+        delete(c.prefetched, trace.Key)
+        if c.feedback != nil {
+            c.feedback.Used(trace.Key)
+        }
+    case hit:
+        c.hit++
+    default:
+        c.miss++
+    }
+
+    for _, key := range c.prefetcher.Access(trace.Key, hit) {
+        if c.policy.Contains(key) {
+            if _, ok := c.prefetched[key]; !ok {
+                c.dropped(key)
+            }
+            continue
+        }
+
+        before = c.policy.Stats()
+        err = c.policy.Get(simulator.Trace{
+            Key:        key,
+            Op:         simulator.OpRead,
+            Timestamp:  trace.Timestamp,
+            Size:       trace.Size,
+        })
+        if err != nil {
+            return err
+        }
+        c.issued++
+        c.prefetchWrite += c.policy.Stats().Write - before.Write
+
+        // admission filters such as larc's may turn the page away
+        if c.policy.Contains(key) {
+            c.prefetched[key] = struct{}{}
+        } else {
+            c.dropped(key)
+        }
+    }
+
+    return nil
+}
+
+// Contains reports whether lba is cached, without updating the policy
+func (c *Cache) Contains(lba simulator.Key) bool {
+    return c.policy.Contains(lba)
+}
+
+// dropped tells the prefetcher that lba will be neither used nor wasted
+func (c *Cache) dropped(lba simulator.Key) {
+    if c.feedback != nil {
+        c.feedback.Dropped(lba)
+    }
+}
+
+// Remove drops lba from the cache, returning whether it was cached
+func (c *Cache) Remove(lba simulator.Key) (removed bool) {
+    if _, ok := c.prefetched[lba]; ok {
+        delete(c.prefetched, lba)
+        c.dropped(lba)
+    }
+    return c.policy.Remove(lba)
+}
+
+// OnEvict registers fn to be called with every page evicted from the cache
+func (c *Cache) OnEvict(fn func(lba simulator.Key)) {
+    c.onEvict = fn
+}
+
+// SetPurgeGhosts forwards the option to policies keeping ghost lists
+func (c *Cache) SetPurgeGhosts(purge bool) {
+    if purger, ok := c.policy.(interface{ SetPurgeGhosts(bool) }); ok {
+        purger.SetPurgeGhosts(purge)
+    }
+}
+
+// Stats counts the hits of demand requests on prefetched pages as hits, and
+// the writes caused by prefetches as writes
+func (c *Cache) Stats() simulator.Stats {
+    stats := c.policy.Stats()
+
+    return simulator.Stats{
+        Hit:        c.hit + c.prefetchHit,
+        Miss:       c.miss,
+        Write:      c.wc + c.prefetchWrite,
+        Eviction:   stats.Eviction,
+        Delete:     c.deleted,
+        Occupancy:  stats.Occupancy,
+        Capacity:   stats.Capacity,
+    }
+}
+
+func (c *Cache) PrintToFile(file io.Writer, elapsed time.Duration) (err error) {
+    stats := c.Stats()
+
+    fmt.Fprintf(file, "cache size: %d\n", stats.Capacity)
+    fmt.Fprintf(file, "cache hit: %d\n", stats.Hit)
+    fmt.Fprintf(file, "cache miss: %d\n", stats.Miss)
+    fmt.Fprintf(file, "cache hit ratio: %.4f%%\n", float64(stats.Hit) / float64(stats.Hit + stats.Miss) * 100)
+    fmt.Fprintf(file, "write count: %d\n", stats.Write)
+    fmt.Fprintf(file, "delete count: %d\n", c.deleted)
+    fmt.Fprintf(file, "demand hit: %d\n", c.hit)
+    fmt.Fprintf(file, "demand write count: %d\n", c.wc)
+    fmt.Fprintf(file, "prefetch hit: %d\n", c.prefetchHit)
+    fmt.Fprintf(file, "prefetch issued: %d\n", c.issued)
+    fmt.Fprintf(file, "prefetch wasted: %d\n", c.wasted)
+    fmt.Fprintf(file, "prefetch write count: %d\n", c.prefetchWrite)
+    fmt.Fprintf(file, "time execution: %8.4f\n", elapsed.Seconds())
+
+    return nil
+}
+
+// Details returns the details of the policy, if it has any
+func (c *Cache) Details() interface{} {
+    if details, ok := c.policy.(simulator.Details); ok {
+        return details.Details()
+    }
+    return nil
+}
+
+func (c *telemetryCache) PrintTelemetryToFile(file io.Writer) (err error) {
+    return c.policy.(simulator.Telemetry).PrintTelemetryToFile(file)
+}
@@ -0,0 +1,225 @@
+package prefetch
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+    "github.com/secnot/orderedmap"
+)
+
+// Prefetchers lists the names accepted by Parse
+var Prefetchers = []string{"readahead", "sequential", "stride"}
+
+type (
+    // Readahead fetches the pages following every demand miss
+    Readahead struct {
+        pages       int
+    }
+
+    // stream is a sequential stream followed by Sequential
+    stream struct {
+        length      int             // consecutive pages requested
+        degree      int             // pages prefetched at a time
+        end         simulator.Key   // last page prefetched
+        trigger     simulator.Key   // page whose request prefetches the next group
+        prefetching bool
+    }
+
+    // Sequential detects sequential streams and prefetches ahead of them,
+    // adapting the degree of every stream as AMP does: it grows when the
+    // last page of a group is requested, so the prefetches came too late,
+    // and shrinks when a prefetched page is evicted unused.
+    Sequential struct {
+        initial     int
+        max         int
+        streams     *orderedmap.OrderedMap          // next expected page -> stream
+        owners      map[simulator.Key]*stream       // prefetched page -> its stream
+        limit       int                             // streams followed at once
+    }
+
+    // Stride detects requests separated by a constant stride and prefetches
+    // the next pages of the pattern
+    Stride struct {
+        degree      int
+        last        int64
+        stride      int64
+        confidence  int
+        started     bool
+    }
+)
+
+// Parse reads a prefetcher spec: a name of Prefetchers, optionally followed
+// by ":degree", e.g. "readahead:8"
+func Parse(spec string) (prefetcher Prefetcher, err error) {
+    var degree int
+
+    name, value, hasDegree := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ":")
+    if hasDegree {
+        if degree, err = strconv.Atoi(value); err != nil || degree < 1 {
+            return nil, fmt.Errorf("invalid prefetch degree in %q", spec)
+        }
+    }
+
+    switch name {
+    case "readahead":
+        if !hasDegree {
+            degree = 8
+        }
+        return NewReadahead(degree), nil
+    case "sequential", "amp":
+        if !hasDegree {
+            degree = 64
+        }
+        return NewSequential(4, degree), nil
+    case "stride":
+        if !hasDegree {
+            degree = 4
+        }
+        return NewStride(degree), nil
+    default:
+        return nil, fmt.Errorf("unknown prefetcher %q, expected one of %v", name, Prefetchers)
+    }
+}
+
+func NewReadahead(pages int) *Readahead {
+    return &Readahead{
+        pages:  pages,
+    }
+}
+
+func (r *Readahead) Access(key simulator.Key, hit bool) (keys []simulator.Key) {
+    if hit {
+        return nil
+    }
+
+    for i := 0; i < r.pages; i++ {
+        key = key.Next()
+        keys = append(keys, key)
+    }
+    return keys
+}
+
+// NewSequential creates a sequential prefetcher whose degree starts at
+// initial pages and adapts up to max pages
+func NewSequential(initial int, max int) *Sequential {
+    if initial > max {
+        initial = max
+    }
+
+    return &Sequential{
+        initial:    initial,
+        max:        max,
+        streams:    orderedmap.NewOrderedMap(),
+        owners:     make(map[simulator.Key]*stream),
+        limit:      256,
+    }
+}
+
+func (s *Sequential) Access(key simulator.Key, hit bool) (keys []simulator.Key) {
+    var st *stream
+
+    if value, ok := s.streams.Get(key.MapKey()); ok {
+        st = value.(*stream)
+        s.streams.Delete(key.MapKey())
+        st.length++
+    } else {
+        st = &stream{length: 1, degree: s.initial}
+        if s.streams.Len() >= s.limit {
+            s.streams.PopFirst()
+        }
+    }
+    s.streams.Set(key.Next().MapKey(), st)
+
+    // a stream needs two consecutive pages, and a new group of prefetches
+    // is issued on a miss or once the trigger page is reached
+    if st.length < 2 || (st.prefetching && hit && key != st.trigger) {
+        return nil
+    }
+
+    from := key
+    if st.prefetching && !st.end.Less(key) {
+        from = st.end
+    }
+
+    for i := 0; i < st.degree; i++ {
+        from = from.Next()
+        keys = append(keys, from)
+        s.owners[from] = st
+    }
+
+    st.prefetching = true
+    st.end = from
+    st.trigger = keys[len(keys) / 2]
+
+    return keys
+}
+
+func (s *Sequential) Used(key simulator.Key) {
+    st, ok := s.owners[key]
+    if !ok {
+        return
+    }
+    delete(s.owners, key)
+
+    if key == st.end && st.degree < s.max {
+        st.degree++
+    }
+}
+
+func (s *Sequential) Wasted(key simulator.Key) {
+    st, ok := s.owners[key]
+    if !ok {
+        return
+    }
+    delete(s.owners, key)
+
+    if st.degree > 1 {
+        st.degree--
+    }
+}
+
+func (s *Sequential) Dropped(key simulator.Key) {
+    delete(s.owners, key)
+}
+
+func NewStride(degree int) *Stride {
+    return &Stride{
+        degree: degree,
+    }
+}
+
+// Access follows block addresses only, hashed keys have no stride
+func (s *Stride) Access(key simulator.Key, hit bool) (keys []simulator.Key) {
+    if key.Hi != 0 {
+        return nil
+    }
+    addr := int64(key.Lo)
+
+    if s.started {
+        stride := addr - s.last
+        if stride != 0 && stride == s.stride {
+            s.confidence++
+        } else {
+            s.confidence = 0
+            s.stride = stride
+        }
+    }
+    s.last = addr
+    s.started = true
+
+    if s.confidence < 2 {
+        return nil
+    }
+
+    for i := 1; i <= s.degree; i++ {
+        next := addr + int64(i) * s.stride
+        // strides stay within the volume of the address
+        if next < 0 || next >> simulator.VolumeBits != addr >> simulator.VolumeBits {
+            break
+        }
+        keys = append(keys, simulator.IntKey(uint64(next)))
+    }
+    return keys
+}
@@ -0,0 +1,221 @@
+package reader
+
+import (
+    "bufio"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "math"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// The binary trace format starts with a header
+//
+//  magic       [4]byte "GCTR"
+//  version     uint16
+//  flags       uint16
+//  source      uint16 length followed by the source description
+//
+// followed by fixed-width little-endian records
+//
+//  timestamp   float64 seconds
+//  address     uint64
+//  size        uint32 bytes
+//  op          uint8 (0 read, 1 write, 2 delete)
+//  object id   128-bit key as two uint64, high half first, only when
+//              FlagObjectID is set (a single uint64 in version 1)
+//  tenant      uint32, only when FlagTenant is set (since version 3)
+//
+// Object-cache traces set FlagObjectID: the request key is then stored as
+// the object id and the address is left zero.
+const (
+    BinaryVersion   uint16 = 3
+    FlagObjectID    uint16 = 1 << 0
+    FlagTenant      uint16 = 1 << 1
+
+    binaryRecordSize        = 8 + 8 + 4 + 1
+    binaryObjectRecordSize  = binaryRecordSize + 16
+    binaryV1ObjectSize      = binaryRecordSize + 8
+)
+
+var binaryMagic = []byte("GCTR")
+
+type (
+    // Header describes a binary trace
+    Header struct {
+        Version     uint16
+        Flags       uint16
+        Source      string // where the trace was converted from
+    }
+
+    // Writer encodes traces in the binary format
+    Writer struct {
+        w           *bufio.Writer
+        header      Header
+        record      []byte
+    }
+
+    binaryReader struct {
+        path        string
+        records     int
+        r           *bufio.Reader
+        closer      io.Closer
+        header      Header
+        record      []byte
+    }
+)
+
+// NewWriter writes the header to w and returns a Writer for the records;
+// Flush must be called once every record is written
+func NewWriter(w io.Writer, header Header) (writer *Writer, err error) {
+    var buffered *bufio.Writer = bufio.NewWriterSize(w, 1 << 16)
+
+    if len(header.Source) > math.MaxUint16 {
+        header.Source = header.Source[:math.MaxUint16]
+    }
+    header.Version = BinaryVersion
+
+    buffered.Write(binaryMagic)
+    binary.Write(buffered, binary.LittleEndian, header.Version)
+    binary.Write(buffered, binary.LittleEndian, header.Flags)
+    binary.Write(buffered, binary.LittleEndian, uint16(len(header.Source)))
+    if _, err = buffered.WriteString(header.Source); err != nil {
+        return nil, err
+    }
+
+    writer = &Writer{
+        w:          buffered,
+        header:     header,
+        record:     make([]byte, recordSize(header)),
+    }
+
+    return writer, nil
+}
+
+// recordSize returns the size of the records of a binary trace
+func recordSize(header Header) (size int) {
+    size = binaryRecordSize
+    if header.Flags & FlagObjectID != 0 {
+        size = binaryObjectRecordSize
+        if header.Version == 1 {
+            size = binaryV1ObjectSize
+        }
+    }
+    if header.Flags & FlagTenant != 0 {
+        size += 4
+    }
+    return size
+}
+
+func (w *Writer) Write(trace simulator.Trace) (err error) {
+    var address uint64 = trace.Key.Lo
+
+    if w.header.Flags & FlagObjectID != 0 {
+        binary.LittleEndian.PutUint64(w.record[21:], trace.Key.Hi)
+        binary.LittleEndian.PutUint64(w.record[29:], trace.Key.Lo)
+        address = 0
+    } else if trace.Key.Hi != 0 {
+        return fmt.Errorf("key %v does not fit a 64-bit address, store it as an object id", trace.Key)
+    }
+
+    if w.header.Flags & FlagTenant != 0 {
+        binary.LittleEndian.PutUint32(w.record[len(w.record) - 4:], uint32(trace.Tenant))
+    }
+
+    binary.LittleEndian.PutUint64(w.record[0:], math.Float64bits(trace.Timestamp))
+    binary.LittleEndian.PutUint64(w.record[8:], address)
+    binary.LittleEndian.PutUint32(w.record[16:], uint32(trace.Size))
+    w.record[20] = uint8(trace.Op)
+
+    _, err = w.w.Write(w.record)
+    return err
+}
+
+func (w *Writer) Flush() error {
+    return w.w.Flush()
+}
+
+// isBinary reports whether the stream starts with the binary trace magic
+func isBinary(r *bufio.Reader) bool {
+    magic, _ := r.Peek(len(binaryMagic))
+    return string(magic) == string(binaryMagic)
+}
+
+func newBinaryReader(r *bufio.Reader, closer io.Closer, path string) (reader *binaryReader, err error) {
+    var (
+        magic   []byte = make([]byte, len(binaryMagic))
+        length  uint16
+        source  []byte
+    )
+
+    reader = &binaryReader{path: path, r: r, closer: closer}
+
+    if _, err = io.ReadFull(r, magic); err != nil || string(magic) != string(binaryMagic) {
+        return nil, errors.New("not a binary trace")
+    }
+    if err = binary.Read(r, binary.LittleEndian, &reader.header.Version); err != nil {
+        return nil, err
+    }
+    if reader.header.Version < 1 || reader.header.Version > BinaryVersion {
+        return nil, fmt.Errorf("binary trace version %d not supported", reader.header.Version)
+    }
+    if err = binary.Read(r, binary.LittleEndian, &reader.header.Flags); err != nil {
+        return nil, err
+    }
+    if err = binary.Read(r, binary.LittleEndian, &length); err != nil {
+        return nil, err
+    }
+    source = make([]byte, length)
+    if _, err = io.ReadFull(r, source); err != nil {
+        return nil, err
+    }
+    reader.header.Source = string(source)
+
+    reader.record = make([]byte, recordSize(reader.header))
+
+    return reader, nil
+}
+
+func (r *binaryReader) Read() (trace simulator.Trace, err error) {
+    if _, err = io.ReadFull(r.r, r.record); err != nil {
+        if errors.Is(err, io.ErrUnexpectedEOF) {
+            return trace, fmt.Errorf("%v: record %d: truncated binary trace record", r.path, r.records)
+        }
+        return trace, err
+    }
+    r.records++
+
+    trace.Timestamp = math.Float64frombits(binary.LittleEndian.Uint64(r.record[0:]))
+    trace.Key = simulator.IntKey(binary.LittleEndian.Uint64(r.record[8:]))
+    trace.Size = int(binary.LittleEndian.Uint32(r.record[16:]))
+    trace.Op = simulator.Op(r.record[20])
+    if !trace.Op.Valid() {
+        return trace, fmt.Errorf("%v: record %d: invalid operation %d", r.path, r.records - 1, r.record[20])
+    }
+
+    if r.header.Flags & FlagObjectID != 0 {
+        if r.header.Version == 1 {
+            trace.Key = simulator.IntKey(binary.LittleEndian.Uint64(r.record[21:]))
+        } else {
+            trace.Key.Hi = binary.LittleEndian.Uint64(r.record[21:])
+            trace.Key.Lo = binary.LittleEndian.Uint64(r.record[29:])
+        }
+    }
+
+    if r.header.Flags & FlagTenant != 0 {
+        trace.Tenant = int(binary.LittleEndian.Uint32(r.record[len(r.record) - 4:]))
+    }
+
+    return trace, nil
+}
+
+// Header returns the header of the binary trace
+func (r *binaryReader) Header() Header {
+    return r.header
+}
+
+func (r *binaryReader) Close() error {
+    return r.closer.Close()
+}
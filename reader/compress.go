@@ -0,0 +1,68 @@
+package reader
+
+import (
+    "bufio"
+    "bytes"
+    "compress/gzip"
+    "io"
+    "path/filepath"
+    "strings"
+
+    "github.com/klauspost/compress/zstd"
+    "github.com/ulikunitz/xz"
+)
+
+var (
+    gzipMagic   = []byte{0x1f, 0x8b}
+    zstdMagic   = []byte{0x28, 0xb5, 0x2f, 0xfd}
+    xzMagic     = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// decompressor releases the resources of a decompressing stream
+type decompressor struct {
+    io.Reader
+    close   func()
+}
+
+func (d *decompressor) Close() error {
+    if d.close != nil {
+        d.close()
+    }
+    return nil
+}
+
+// decompress wraps r in a streaming decompressor chosen by the magic bytes
+// of the stream, or by the extension of path when the stream is too short
+// to tell. Uncompressed streams are returned as they are.
+func decompress(r io.Reader, path string) (stream *decompressor, err error) {
+    var (
+        buffered    *bufio.Reader = bufio.NewReaderSize(r, 1 << 16)
+        magic       []byte
+        extension   string = strings.ToLower(filepath.Ext(path))
+    )
+
+    magic, _ = buffered.Peek(len(xzMagic))
+
+    switch {
+    case bytes.HasPrefix(magic, gzipMagic) || (len(magic) < len(gzipMagic) && extension == ".gz"):
+        gz, err := gzip.NewReader(buffered)
+        if err != nil {
+            return nil, err
+        }
+        return &decompressor{Reader: gz, close: func() { gz.Close() }}, nil
+    case bytes.HasPrefix(magic, zstdMagic) || (len(magic) < len(zstdMagic) && extension == ".zst"):
+        zr, err := zstd.NewReader(buffered)
+        if err != nil {
+            return nil, err
+        }
+        return &decompressor{Reader: zr, close: zr.Close}, nil
+    case bytes.HasPrefix(magic, xzMagic) || (len(magic) < len(xzMagic) && extension == ".xz"):
+        xr, err := xz.NewReader(buffered)
+        if err != nil {
+            return nil, err
+        }
+        return &decompressor{Reader: xr}, nil
+    default:
+        return &decompressor{Reader: buffered}, nil
+    }
+}
@@ -0,0 +1,82 @@
+package reader
+
+import (
+    "errors"
+    "io"
+    "strconv"
+    "strings"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// csvColumns are the column names of a csv header
+var csvColumns = []string{"addr|address|key|lba", "op|operation|type", "timestamp|time", "size", "tenant"}
+
+// csvReader reads "addr,op[,timestamp[,size[,tenant]]]" lines, the
+// timestamp being in seconds, the size in bytes and the tenant a number. The address is a number, or any string
+// key with the StringKeys option.
+type csvReader struct {
+    *lines
+    trace   simulator.Trace
+}
+
+func newCSVReader(r io.Reader, closer io.Closer, path string, options Options) *csvReader {
+    return &csvReader{
+        lines:  newLines(r, closer, path, options, csvColumns),
+    }
+}
+
+func (r *csvReader) Read() (trace simulator.Trace, err error) {
+    if err = r.read(r.parse); err != nil {
+        return trace, err
+    }
+    return r.trace, nil
+}
+
+func (r *csvReader) parse(text string) (err error) {
+    var (
+        row     []string = strings.Split(text, ",")
+        trace   simulator.Trace
+    )
+
+    if len(row) < 2 {
+        return errors.New("expected addr,op[,timestamp[,size[,tenant]]]")
+    }
+
+    trace.Key, err = r.parseKey(strings.TrimSpace(row[0]))
+    if err != nil {
+        return err
+    }
+
+    trace.Op, err = simulator.ParseOp(row[1])
+    if err != nil {
+        return err
+    }
+
+    // optional third column: request time in seconds
+    if len(row) > 2 {
+        trace.Timestamp, err = strconv.ParseFloat(strings.TrimSpace(row[2]), 64)
+        if err != nil {
+            return errors.New("invalid timestamp " + strconv.Quote(row[2]))
+        }
+    }
+
+    // optional fourth column: request size in bytes
+    if len(row) > 3 {
+        trace.Size, err = strconv.Atoi(strings.TrimSpace(row[3]))
+        if err != nil {
+            return errors.New("invalid size " + strconv.Quote(row[3]))
+        }
+    }
+
+    // optional fifth column: tenant, e.g. volume or client id
+    if len(row) > 4 {
+        trace.Tenant, err = strconv.Atoi(strings.TrimSpace(row[4]))
+        if err != nil || trace.Tenant < 0 {
+            return errors.New("invalid tenant " + strconv.Quote(row[4]))
+        }
+    }
+
+    r.trace = trace
+    return nil
+}
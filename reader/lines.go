@@ -0,0 +1,215 @@
+package reader
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// maxLineSize bounds the length of a trace line
+const maxLineSize = 1 << 20
+
+type (
+    // Options control how lenient the text readers are
+    Options struct {
+        SkipBadLines    bool // skip unparsable lines instead of failing
+        Hex             bool // addresses are hexadecimal even without 0x
+        StringKeys      bool // addresses are opaque strings, e.g. URLs
+    }
+
+    // ParseError locates a record that could not be parsed
+    ParseError struct {
+        Path    string
+        Line    int
+        Err     error
+    }
+
+    // Skipper is implemented by readers that can skip bad records
+    Skipper interface {
+        Skipped() int
+    }
+
+    // lines scans a line-oriented trace, dropping blank lines and "#"
+    // comments and keeping track of the position for error reports
+    lines struct {
+        path        string
+        options     Options
+        columns     []string // names a header may give every column, "|" separated
+        closer      io.Closer
+        scanner     *bufio.Scanner
+        line        int
+        seen        int // lines holding a record or a header
+        records     int
+        skipped     int
+    }
+)
+
+func (e *ParseError) Error() string {
+    return fmt.Sprintf("%v:%d: %v", e.Path, e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+    return e.Err
+}
+
+// Skipped returns the number of bad records r skipped so far, zero for
+// readers that never skip
+func Skipped(r Reader) int {
+    if skipper, ok := r.(Skipper); ok {
+        return skipper.Skipped()
+    }
+    return 0
+}
+
+func newLines(r io.Reader, closer io.Closer, path string, options Options, columns []string) *lines {
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([]byte, 64 * 1024), maxLineSize)
+
+    return &lines{
+        path:       path,
+        options:    options,
+        columns:    columns,
+        closer:     closer,
+        scanner:    scanner,
+    }
+}
+
+// next returns the next line holding a record
+func (l *lines) next() (text string, err error) {
+    for l.scanner.Scan() {
+        l.line++
+        text = strings.TrimSpace(l.scanner.Text())
+        if text == "" || strings.HasPrefix(text, "#") {
+            continue
+        }
+        l.seen++
+        return text, nil
+    }
+
+    if err = l.scanner.Err(); err != nil {
+        return "", &ParseError{Path: l.path, Line: l.line + 1, Err: err}
+    }
+    return "", io.EOF
+}
+
+// isHeader reports whether text names the columns of the trace, e.g.
+// "addr,op,timestamp": every field must be a name of its column, ignoring
+// case, and "_" or "-" standing for spaces
+func (l *lines) isHeader(text string) bool {
+    fields := strings.Split(text, ",")
+    if len(fields) > len(l.columns) {
+        return false
+    }
+
+    for i, field := range fields {
+        name := strings.ToLower(strings.TrimSpace(field))
+        name = strings.NewReplacer("_", " ", "-", " ").Replace(name)
+        found := false
+        for _, column := range strings.Split(l.columns[i], "|") {
+            if name == column {
+                found = true
+                break
+            }
+        }
+        if !found {
+            return false
+        }
+    }
+    return true
+}
+
+// read returns the next record parsed by parse. The first line is skipped
+// when it is a header naming the columns; bad lines are skipped or
+// reported depending on the options.
+func (l *lines) read(parse func(text string) error) (err error) {
+    var text string
+
+    for {
+        if text, err = l.next(); err != nil {
+            return err
+        }
+
+        if err = parse(text); err == nil {
+            l.records++
+            return nil
+        }
+
+        if l.records == 0 && l.seen == 1 && l.isHeader(text) {
+            continue
+        }
+
+        if !l.options.SkipBadLines {
+            return &ParseError{Path: l.path, Line: l.line, Err: err}
+        }
+        l.skipped++
+    }
+}
+
+func (l *lines) Skipped() int {
+    return l.skipped
+}
+
+func (l *lines) Close() error {
+    return l.closer.Close()
+}
+
+// parseKey reads a decimal or "0x" prefixed hexadecimal address, or a
+// hexadecimal one when the Hex option is set. Hexadecimal addresses may be
+// up to 128 bits wide, e.g. object ids, decimal ones up to 2^64 - 1. With
+// the StringKeys option any text is accepted and hashed.
+func (l *lines) parseKey(text string) (key simulator.Key, err error) {
+    var (
+        value   uint64
+        digits  string = text
+        hex     bool   = l.options.Hex
+    )
+
+    if l.options.StringKeys {
+        if text == "" {
+            return key, errors.New("empty key")
+        }
+        return simulator.StringKey(text), nil
+    }
+
+    if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X") {
+        digits = text[2:]
+        hex = true
+    }
+
+    if !hex {
+        value, err = strconv.ParseUint(digits, 10, 64)
+        if err != nil {
+            return key, fmt.Errorf("invalid address %q: %v", text, numError(err))
+        }
+        return simulator.IntKey(value), nil
+    }
+
+    if digits == "" || len(digits) > 32 {
+        return key, fmt.Errorf("invalid address %q: expected 1 to 32 hexadecimal digits", text)
+    }
+    if len(digits) > 16 {
+        if key.Hi, err = strconv.ParseUint(digits[:len(digits) - 16], 16, 64); err != nil {
+            return key, fmt.Errorf("invalid address %q: %v", text, numError(err))
+        }
+        digits = digits[len(digits) - 16:]
+    }
+    if key.Lo, err = strconv.ParseUint(digits, 16, 64); err != nil {
+        return key, fmt.Errorf("invalid address %q: %v", text, numError(err))
+    }
+
+    return key, nil
+}
+
+// numError strips the function and input from strconv errors
+func numError(err error) error {
+    var numErr *strconv.NumError
+    if errors.As(err, &numErr) {
+        return numErr.Err
+    }
+    return err
+}
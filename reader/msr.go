@@ -0,0 +1,85 @@
+package reader
+
+import (
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// msrTicks is the number of Windows filetime ticks in a second
+const msrTicks = 1e7
+
+// msrColumns are the column names of an MSR header
+var msrColumns = []string{"timestamp", "hostname", "disk number|disknumber", "type", "offset", "size", "response time|responsetime"}
+
+// msrReader reads the MSR Cambridge block traces, one request per line
+//
+//  timestamp,hostname,disk number,type,offset,size,response time
+//
+// The timestamp is a Windows filetime and the offset is in bytes; requests
+// are keyed by 512-byte sector. The disk number is the tenant and is placed
+// above the sector in the key, every disk being its own address space. The
+// response time is not used and may be missing.
+type msrReader struct {
+    *lines
+    trace   simulator.Trace
+}
+
+func newMSRReader(r io.Reader, closer io.Closer, path string, options Options) *msrReader {
+    return &msrReader{
+        lines:  newLines(r, closer, path, options, msrColumns),
+    }
+}
+
+func (r *msrReader) Read() (trace simulator.Trace, err error) {
+    if err = r.read(r.parse); err != nil {
+        return trace, err
+    }
+    return r.trace, nil
+}
+
+func (r *msrReader) parse(text string) (err error) {
+    var (
+        row     []string = strings.Split(text, ",")
+        trace   simulator.Trace
+        ticks   uint64
+        disk    uint64
+        offset  uint64
+    )
+
+    if len(row) < 6 {
+        return fmt.Errorf("%d fields, expected at least 6", len(row))
+    }
+
+    if ticks, err = strconv.ParseUint(strings.TrimSpace(row[0]), 10, 64); err != nil {
+        return fmt.Errorf("invalid timestamp %q", row[0])
+    }
+    trace.Timestamp = float64(ticks) / msrTicks
+
+    if disk, err = strconv.ParseUint(strings.TrimSpace(row[2]), 10, 64 - simulator.VolumeBits); err != nil {
+        return fmt.Errorf("invalid disk number %q", row[2])
+    }
+    trace.Tenant = int(disk)
+
+    if trace.Op, err = simulator.ParseOp(row[3]); err != nil {
+        return err
+    }
+
+    if offset, err = strconv.ParseUint(strings.TrimSpace(row[4]), 10, 64); err != nil {
+        return fmt.Errorf("invalid offset %q", row[4])
+    }
+    if offset / 512 >= 1 << simulator.VolumeBits {
+        return fmt.Errorf("invalid offset %q", row[4])
+    }
+    trace.Key = simulator.VolumeKey(disk, offset / 512)
+
+    if trace.Size, err = strconv.Atoi(strings.TrimSpace(row[5])); err != nil {
+        return fmt.Errorf("invalid size %q", row[5])
+    }
+
+    r.trace = trace
+    return nil
+}
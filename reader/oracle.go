@@ -0,0 +1,59 @@
+package reader
+
+import (
+    "bufio"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// oracleRecordSize is the size of a libCacheSim oracleGeneral record
+//
+//  clock time          uint32 seconds
+//  object id           uint64
+//  object size         uint32 bytes
+//  next access vtime   int64, -1 when never accessed again
+const oracleRecordSize = 4 + 8 + 4 + 8
+
+// oracleReader reads libCacheSim oracleGeneral traces. The format has no
+// header and no operation, every record is a read.
+type oracleReader struct {
+    path    string
+    records int
+    r       *bufio.Reader
+    closer  io.Closer
+    record  []byte
+}
+
+func newOracleReader(r *bufio.Reader, closer io.Closer, path string) *oracleReader {
+    return &oracleReader{
+        path:       path,
+        r:          r,
+        closer:     closer,
+        record:     make([]byte, oracleRecordSize),
+    }
+}
+
+func (r *oracleReader) Read() (trace simulator.Trace, err error) {
+    if _, err = io.ReadFull(r.r, r.record); err != nil {
+        if errors.Is(err, io.ErrUnexpectedEOF) {
+            return trace, fmt.Errorf("%v: record %d: truncated oracleGeneral record", r.path, r.records)
+        }
+        return trace, err
+    }
+    r.records++
+
+    trace.Timestamp = float64(binary.LittleEndian.Uint32(r.record[0:]))
+    trace.Key = simulator.IntKey(binary.LittleEndian.Uint64(r.record[4:]))
+    trace.Size = int(binary.LittleEndian.Uint32(r.record[12:]))
+    trace.Op = simulator.OpRead
+
+    return trace, nil
+}
+
+func (r *oracleReader) Close() error {
+    return r.closer.Close()
+}
@@ -0,0 +1,111 @@
+package reader
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// Reader streams the records of a trace file one at a time; Read returns
+// io.EOF once the trace is exhausted
+type Reader interface {
+    Read() (simulator.Trace, error)
+    Close() error
+}
+
+// Formats lists the trace formats Open understands
+var Formats = []string{"auto", "csv", "binary", "oracle", "twitter", "spc", "msr"}
+
+// Open opens a trace file in the given format: "csv" for the
+// "addr,op[,timestamp[,size[,tenant]]]" text format, "binary" for the binary
+// format, "oracle" for libCacheSim oracleGeneral traces, "twitter" for the
+// Twitter memcached traces, "spc" for the SPC traces of the UMass trace
+// repository, "msr" for the MSR Cambridge traces, "auto" or "" to tell csv
+// and binary apart by the binary magic. Files compressed with gzip, zstd or
+// xz are decompressed on the fly. Errors in text formats are reported as *ParseError.
+func Open(path string, format string, options Options) (r Reader, err error) {
+    var (
+        file    *os.File
+        stream  *decompressor
+        buffer  *bufio.Reader
+    )
+
+    file, err = os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+
+    stream, err = decompress(file, path)
+    if err != nil {
+        file.Close()
+        return nil, fmt.Errorf("%v: %v", path, err)
+    }
+    closer := closers{stream, file}
+    buffer = bufio.NewReaderSize(stream, 1 << 16)
+
+    switch strings.ToLower(format) {
+    case "", "auto":
+        if isBinary(buffer) {
+            return openBinary(buffer, closer, path)
+        }
+        return newCSVReader(buffer, closer, path, options), nil
+    case "csv":
+        return newCSVReader(buffer, closer, path, options), nil
+    case "binary", "bin":
+        return openBinary(buffer, closer, path)
+    case "oracle", "oraclegeneral":
+        return newOracleReader(buffer, closer, path), nil
+    case "twitter":
+        return newTwitterReader(buffer, closer, path, options), nil
+    case "spc", "umass":
+        return newSPCReader(buffer, closer, path, options), nil
+    case "msr":
+        return newMSRReader(buffer, closer, path, options), nil
+    default:
+        closer.Close()
+        return nil, fmt.Errorf("trace format %v not supported", format)
+    }
+}
+
+func openBinary(buffer *bufio.Reader, closer io.Closer, path string) (r Reader, err error) {
+    r, err = newBinaryReader(buffer, closer, path)
+    if err != nil {
+        closer.Close()
+        return nil, fmt.Errorf("%v: %v", path, err)
+    }
+    return r, nil
+}
+
+// closers closes a stack of streams, innermost first
+type closers []io.Closer
+
+func (c closers) Close() (err error) {
+    for _, closer := range c {
+        if e := closer.Close(); e != nil && err == nil {
+            err = e
+        }
+    }
+    return err
+}
+
+// ReadAll loads every remaining record of r into memory
+func ReadAll(r Reader) (traces []simulator.Trace, err error) {
+    var trace simulator.Trace
+
+    traces = make([]simulator.Trace, 0)
+    for {
+        trace, err = r.Read()
+        if errors.Is(err, io.EOF) {
+            return traces, nil
+        }
+        if err != nil {
+            return traces, err
+        }
+        traces = append(traces, trace)
+    }
+}
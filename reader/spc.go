@@ -0,0 +1,75 @@
+package reader
+
+import (
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// spcColumns are the column names of an SPC header
+var spcColumns = []string{"asu", "lba", "size", "opcode", "timestamp"}
+
+// spcReader reads the SPC traces of the UMass trace repository, such as
+// Financial and WebSearch, one request per line
+//
+//  ASU,LBA,size,opcode,timestamp
+//
+// The application specific unit is the tenant; since every unit is its own
+// address space, it is also placed above the LBA in the key.
+type spcReader struct {
+    *lines
+    trace   simulator.Trace
+}
+
+func newSPCReader(r io.Reader, closer io.Closer, path string, options Options) *spcReader {
+    return &spcReader{
+        lines:  newLines(r, closer, path, options, spcColumns),
+    }
+}
+
+func (r *spcReader) Read() (trace simulator.Trace, err error) {
+    if err = r.read(r.parse); err != nil {
+        return trace, err
+    }
+    return r.trace, nil
+}
+
+func (r *spcReader) parse(text string) (err error) {
+    var (
+        row     []string = strings.Split(text, ",")
+        trace   simulator.Trace
+        asu     uint64
+        lba     uint64
+    )
+
+    if len(row) < 5 {
+        return fmt.Errorf("%d fields, expected 5", len(row))
+    }
+
+    if asu, err = strconv.ParseUint(strings.TrimSpace(row[0]), 10, 64 - simulator.VolumeBits); err != nil {
+        return fmt.Errorf("invalid ASU %q", row[0])
+    }
+    if lba, err = strconv.ParseUint(strings.TrimSpace(row[1]), 10, simulator.VolumeBits); err != nil {
+        return fmt.Errorf("invalid LBA %q", row[1])
+    }
+    trace.Key = simulator.VolumeKey(asu, lba)
+    trace.Tenant = int(asu)
+
+    if trace.Size, err = strconv.Atoi(strings.TrimSpace(row[2])); err != nil {
+        return fmt.Errorf("invalid size %q", row[2])
+    }
+
+    if trace.Op, err = simulator.ParseOp(row[3]); err != nil {
+        return err
+    }
+
+    if trace.Timestamp, err = strconv.ParseFloat(strings.TrimSpace(row[4]), 64); err != nil {
+        return fmt.Errorf("invalid timestamp %q", row[4])
+    }
+
+    r.trace = trace
+    return nil
+}
@@ -0,0 +1,93 @@
+package reader
+
+import (
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// twitterOps maps the memcached commands of the Twitter traces onto reads,
+// writes and deletes
+var twitterOps = map[string]simulator.Op{
+    "get":      simulator.OpRead,
+    "gets":     simulator.OpRead,
+    "set":      simulator.OpWrite,
+    "add":      simulator.OpWrite,
+    "replace":  simulator.OpWrite,
+    "cas":      simulator.OpWrite,
+    "append":   simulator.OpWrite,
+    "prepend":  simulator.OpWrite,
+    "incr":     simulator.OpWrite,
+    "decr":     simulator.OpWrite,
+    "delete":   simulator.OpDelete,
+}
+
+// twitterColumns are the column names of a Twitter header
+var twitterColumns = []string{"timestamp", "anonymized key|key", "key size", "value size", "client id", "operation|op", "ttl"}
+
+// twitterReader reads the Twitter memcached traces, one request per line
+//
+//  timestamp,anonymized key,key size,value size,client id,operation,TTL
+//
+// Keys are hashed into 128-bit keys; the request size is the key size plus
+// the value size, and the client id is the tenant.
+type twitterReader struct {
+    *lines
+    trace   simulator.Trace
+}
+
+func newTwitterReader(r io.Reader, closer io.Closer, path string, options Options) *twitterReader {
+    return &twitterReader{
+        lines:  newLines(r, closer, path, options, twitterColumns),
+    }
+}
+
+func (r *twitterReader) Read() (trace simulator.Trace, err error) {
+    if err = r.read(r.parse); err != nil {
+        return trace, err
+    }
+    return r.trace, nil
+}
+
+func (r *twitterReader) parse(text string) (err error) {
+    var (
+        row         []string = strings.Split(text, ",")
+        trace       simulator.Trace
+        keySize     int
+        valueSize   int
+        ok          bool
+    )
+
+    if len(row) < 7 {
+        return fmt.Errorf("%d fields, expected 7", len(row))
+    }
+
+    trace.Timestamp, err = strconv.ParseFloat(row[0], 64)
+    if err != nil {
+        return fmt.Errorf("invalid timestamp %q", row[0])
+    }
+
+    trace.Key = simulator.StringKey(row[1])
+
+    if keySize, err = strconv.Atoi(row[2]); err != nil {
+        return fmt.Errorf("invalid key size %q", row[2])
+    }
+    if valueSize, err = strconv.Atoi(row[3]); err != nil {
+        return fmt.Errorf("invalid value size %q", row[3])
+    }
+    trace.Size = keySize + valueSize
+
+    if trace.Tenant, err = strconv.Atoi(row[4]); err != nil || trace.Tenant < 0 {
+        return fmt.Errorf("invalid client id %q", row[4])
+    }
+
+    if trace.Op, ok = twitterOps[row[5]]; !ok {
+        return fmt.Errorf("unknown operation %q", row[5])
+    }
+
+    r.trace = trace
+    return nil
+}
@@ -0,0 +1,553 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/mohammadtauchid/golang-cache/v2/arc"
+    "github.com/mohammadtauchid/golang-cache/v2/ensemble"
+    "github.com/mohammadtauchid/golang-cache/v2/flash"
+    "github.com/mohammadtauchid/golang-cache/v2/hierarchy"
+    "github.com/mohammadtauchid/golang-cache/v2/larc"
+    "github.com/mohammadtauchid/golang-cache/v2/latency"
+    "github.com/mohammadtauchid/golang-cache/v2/lru"
+    "github.com/mohammadtauchid/golang-cache/v2/marc"
+    "github.com/mohammadtauchid/golang-cache/v2/partition"
+    "github.com/mohammadtauchid/golang-cache/v2/prefetch"
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+type (
+    // job is a single (algorithm, cache size) simulation of the matrix
+    job struct {
+        index       int
+        algorithm   string
+        cache       int
+        relative    float64 // cache size as a fraction of the working set
+        workingSet  int     // unique addresses of the trace, when relative
+    }
+
+    // result holds the rendered output of a job, written in job order
+    result struct {
+        index       int
+        job         job
+        output      bytes.Buffer
+        elapsed     time.Duration
+        summary     summary
+        err         error
+    }
+
+    // summary is the structured result of a job, written to the results file
+    summary struct {
+        Algorithm   string          `json:"algorithm"`
+        CacheSize   int             `json:"cache_size"`
+        Relative    float64         `json:"relative_cache_size,omitempty"`
+        Stats       simulator.Stats `json:"stats"`
+        HitRatio    float64         `json:"hit_ratio"`
+        Warm        *warmSummary    `json:"warm,omitempty"` // after the warm-up phase, when it completed
+        Elapsed     float64         `json:"elapsed_seconds"`
+        Details     interface{}     `json:"details,omitempty"` // policy specific, e.g. ghost list analytics
+        Tenants     []tenantSummary `json:"tenants,omitempty"`
+        Fairness    float64         `json:"fairness,omitempty"` // jain's index of the tenant hit ratios
+    }
+
+    // warmSummary is the part of a result that follows the warm-up phase
+    warmSummary struct {
+        Requests    int             `json:"warmup_requests"`
+        Stats       simulator.Stats `json:"stats"`
+        HitRatio    float64         `json:"hit_ratio"`
+    }
+
+    // tenantSummary is the breakdown of a result for a tenant
+    tenantSummary struct {
+        Tenant      int             `json:"tenant"`
+        Stats       simulator.Stats `json:"stats"`
+        HitRatio    float64         `json:"hit_ratio"`
+    }
+
+    // runOptions are shared by every job of the matrix
+    runOptions struct {
+        window      int
+        windowTime  float64
+        warmup      string
+        purgeGhosts bool
+        outPrefix   string // output/<algorithm>/<timestamp>_<algorithm>_<trace>
+        tiers       []tierSpec
+        inclusion   hierarchy.Inclusion
+        flash       *flash.Device
+        latency     bool // report estimated service times, always done for hierarchies
+        model       latency.Model
+        prefetch    string
+        partition   *partition.Config
+        tenants     []int // distinct tenants of the trace
+        ensemble    ensemble.Config
+        checkpoint  string // directory of the job snapshots, empty to disable
+        fingerprint uint64 // of the trace, named in the snapshots
+        every       int    // requests between two snapshots
+        resume      bool   // continue every job from its snapshot, when there is one
+    }
+
+    // tierSpec is a tier of the -tiers option; the "*" tier takes the
+    // algorithm and cache size of the job
+    tierSpec struct {
+        algorithm   string
+        cache       int
+        latency     float64
+    }
+
+    // ghostPurger is implemented by policies keeping ghost lists
+    ghostPurger interface {
+        SetPurgeGhosts(purge bool)
+    }
+)
+
+func newSimulator(algorithm string, cache int) (sim simulator.Simulator, err error) {
+    switch strings.ToLower(algorithm) {
+    case "lru":
+        sim = lru.NewLRU(cache)
+    // case "lfu":
+    //     sim = lfu.NewLFU(cache)
+    case "arc":
+        sim = arc.NewARC(cache)
+    case "larc":
+        sim = larc.NewLARC(cache)
+    case "marc":
+        sim = marc.NewMARC(cache)
+    default:
+        return nil, fmt.Errorf("algorithm %v not supported", algorithm)
+    }
+
+    return sim, nil
+}
+
+// parseTiers reads a tier list such as "lru:1000@0.1,*@100": each tier is
+// an algorithm and a cache size, optionally followed by its lookup latency
+// in microseconds; "*" stands for the algorithm and cache size of the job
+func parseTiers(spec string) (tiers []tierSpec, err error) {
+    var jobTiers int
+
+    for _, field := range strings.Split(spec, ",") {
+        var tier tierSpec
+
+        field = strings.TrimSpace(field)
+        if at := strings.Index(field, "@"); at >= 0 {
+            if tier.latency, err = strconv.ParseFloat(field[at + 1:], 64); err != nil || tier.latency < 0 {
+                return nil, fmt.Errorf("invalid tier latency in %q", field)
+            }
+            field = field[:at]
+        }
+
+        if field == "*" {
+            jobTiers++
+            tiers = append(tiers, tier)
+            continue
+        }
+
+        parts := strings.Split(field, ":")
+        if len(parts) != 2 {
+            return nil, fmt.Errorf("invalid tier %q, expected algorithm:size[@latency] or *[@latency]", field)
+        }
+        tier.algorithm = parts[0]
+        if tier.cache, err = strconv.Atoi(parts[1]); err != nil || tier.cache < 1 {
+            return nil, fmt.Errorf("invalid tier size in %q", field)
+        }
+        if _, err = newPolicy(tier.algorithm, 1); err != nil {
+            return nil, err
+        }
+        tiers = append(tiers, tier)
+    }
+
+    if jobTiers != 1 {
+        return nil, fmt.Errorf("tier list %q must contain exactly one \"*\" tier", spec)
+    }
+
+    return tiers, nil
+}
+
+// newEnsemble creates an ensemble of the candidate algorithms of the options
+func newEnsemble(cache int, opts runOptions) (sim *ensemble.Ensemble, err error) {
+    return ensemble.NewEnsemble(opts.ensemble, cache, newPolicy)
+}
+
+// newPolicy creates a simulator that can be used as a tier of a hierarchy
+func newPolicy(algorithm string, cache int) (policy simulator.Policy, err error) {
+    sim, err := newSimulator(algorithm, cache)
+    if err != nil {
+        return nil, err
+    }
+
+    policy, ok := sim.(simulator.Policy)
+    if !ok {
+        return nil, fmt.Errorf("algorithm %v cannot be used as a tier", algorithm)
+    }
+
+    return policy, nil
+}
+
+// newJobPolicy creates the policy of a job, behind a prefetcher when one is
+// configured
+func newJobPolicy(j job, opts runOptions) (policy simulator.Policy, err error) {
+    var prefetcher prefetch.Prefetcher
+
+    if strings.ToLower(j.algorithm) == "ensemble" {
+        policy, err = newEnsemble(j.cache, opts)
+    } else {
+        policy, err = newPolicy(j.algorithm, j.cache)
+    }
+    if err != nil || opts.prefetch == "" {
+        return policy, err
+    }
+
+    if prefetcher, err = prefetch.Parse(opts.prefetch); err != nil {
+        return nil, err
+    }
+
+    return prefetch.NewCache(policy, prefetcher), nil
+}
+
+// newJobSimulator creates the simulator of a job, composing it into a
+// hierarchy when tiers are configured
+func newJobSimulator(j job, opts runOptions) (sim simulator.Simulator, err error) {
+    var tiers []*hierarchy.Tier
+
+    if opts.partition != nil {
+        return partition.NewPartitioned(*opts.partition, j.cache, opts.tenants, func(size int) (simulator.Policy, error) {
+            return newPolicy(j.algorithm, size)
+        })
+    }
+
+    if len(opts.tiers) == 0 {
+        if opts.prefetch != "" {
+            return newJobPolicy(j, opts)
+        }
+        if strings.ToLower(j.algorithm) == "ensemble" {
+            return newEnsemble(j.cache, opts)
+        }
+        return newSimulator(j.algorithm, j.cache)
+    }
+
+    for _, spec := range opts.tiers {
+        var policy simulator.Policy
+
+        if spec.algorithm == "" {
+            spec.algorithm = j.algorithm
+            policy, err = newJobPolicy(j, opts)
+        } else {
+            policy, err = newPolicy(spec.algorithm, spec.cache)
+        }
+        if err != nil {
+            return nil, err
+        }
+        tiers = append(tiers, hierarchy.NewTier(strings.ToLower(spec.algorithm), policy, spec.latency))
+    }
+
+    return hierarchy.NewHierarchy(opts.inclusion, opts.model.Miss, tiers...), nil
+}
+
+// runJob simulates one job over the traces and renders its result block
+func runJob(j job, traces []simulator.Trace, opts runOptions) (res *result) {
+    var (
+        sim         simulator.Simulator
+        recorder    *simulator.WindowRecorder
+        warmup      *simulator.WarmUp
+        wear        []*flash.Wear
+        timing      *latency.Recorder
+        tenants     *simulator.TenantStats
+        observers   []simulator.Observer
+        states      []simulator.Checkpointer // saved in the snapshots
+        snapshot    string
+        header      simulator.SnapshotHeader
+        timeStart   time.Time
+        err         error
+    )
+
+    res = &result{index: j.index, job: j}
+
+    sim, err = newJobSimulator(j, opts)
+    if err != nil {
+        res.err = err
+        return res
+    }
+
+    if purger, ok := sim.(ghostPurger); ok {
+        purger.SetPurgeGhosts(opts.purgeGhosts)
+    }
+
+    // partitioned caches report their tenants themselves
+    if p, ok := sim.(*partition.Partitioned); ok {
+        tenants = p.TenantStats()
+    } else if len(opts.tenants) > 1 {
+        tenants = simulator.NewTenantStats()
+        observers = append(observers, tenants)
+    }
+
+    if opts.checkpoint != "" {
+        checkpointer, ok := sim.(simulator.Checkpointer)
+        if !ok {
+            res.err = fmt.Errorf("%v simulations cannot be checkpointed", j.algorithm)
+            return res
+        }
+        states = []simulator.Checkpointer{checkpointer}
+        if tenants != nil {
+            states = append(states, tenants)
+        }
+
+        snapshot = filepath.Join(
+            opts.checkpoint,
+            fmt.Sprintf("%v_%d_%016x.snapshot", strings.ToLower(j.algorithm), j.cache, opts.fingerprint),
+        )
+        header = simulator.SnapshotHeader{
+            Algorithm:      strings.ToLower(j.algorithm),
+            Cache:          j.cache,
+            Traces:         len(traces),
+            Fingerprint:    opts.fingerprint,
+        }
+
+        if _, err = os.Stat(snapshot); opts.resume && err == nil {
+            if header, err = simulator.ReadSnapshot(snapshot, header, states...); err != nil {
+                res.err = err
+                return res
+            }
+            fmt.Printf("%v %d: resuming at request %d\n", j.algorithm, j.cache, header.Offset)
+        }
+    }
+
+    if opts.window > 0 || opts.windowTime > 0 {
+        recorder = simulator.NewWindowRecorder(opts.window, opts.windowTime)
+        observers = append(observers, recorder)
+    }
+
+    if opts.warmup != "" {
+        warmup, err = simulator.ParseWarmUp(opts.warmup, len(traces))
+        if err != nil {
+            res.err = err
+            return res
+        }
+        observers = append(observers, warmup)
+    }
+
+    if opts.flash != nil {
+        if h, ok := sim.(*hierarchy.Hierarchy); ok {
+            for _, tier := range h.Tiers() {
+                wear = append(wear, flash.NewWear(*opts.flash))
+                tier.AddObserver(wear[len(wear) - 1])
+            }
+        } else {
+            wear = append(wear, flash.NewWear(*opts.flash))
+            observers = append(observers, wear[0])
+        }
+    }
+
+    if _, ok := sim.(*hierarchy.Hierarchy); ok || opts.latency {
+        timing = latency.NewRecorder(opts.model, sim)
+        observers = append(observers, timing)
+    }
+
+    timeStart = time.Now()
+
+    // without snapshots the whole trace is a single step
+    for offset := header.Offset; ; {
+        end := len(traces)
+        if snapshot != "" && opts.every > 0 && offset + opts.every < end {
+            end = offset + opts.every
+        }
+
+        err = simulator.Run(sim, traces[offset:end], observers...)
+        if err != nil {
+            res.err = err
+            return res
+        }
+        offset = end
+
+        if snapshot != "" {
+            header.Offset = offset
+            if err = simulator.WriteSnapshot(snapshot, header, states...); err != nil {
+                res.err = err
+                return res
+            }
+        }
+        if offset >= len(traces) {
+            break
+        }
+    }
+
+    res.elapsed = time.Since(timeStart)
+
+    fmt.Fprintf(&res.output, "======== Algorithm: %4v ========\n", strings.ToUpper(j.algorithm))
+    if j.relative > 0 {
+        fmt.Fprintf(
+            &res.output, "relative cache size: %.4f%% of working set (%d unique addresses)\n",
+            j.relative * 100, j.workingSet,
+        )
+    }
+    sim.PrintToFile(&res.output, res.elapsed)
+    if warmup != nil {
+        warmup.PrintToFile(&res.output, sim.Stats())
+    }
+    if tenants != nil {
+        tenants.PrintToFile(&res.output)
+    }
+    if timing != nil {
+        timing.PrintToFile(&res.output)
+    }
+    if h, ok := sim.(*hierarchy.Hierarchy); ok {
+        for i, tierWear := range wear {
+            tier := h.Tiers()[i]
+            fmt.Fprintf(&res.output, "tier %d (%v) flash wear:\n", i + 1, tier.Name)
+            tierWear.PrintToFile(&res.output, tier.Policy.Stats().Capacity)
+        }
+    } else if len(wear) > 0 {
+        wear[0].PrintToFile(&res.output, sim.Stats().Capacity)
+    }
+    io.WriteString(&res.output, "\n\n")
+
+    stats := sim.Stats()
+    res.summary = summary{
+        Algorithm:  strings.ToLower(j.algorithm),
+        CacheSize:  j.cache,
+        Relative:   j.relative,
+        Stats:      stats,
+        Elapsed:    res.elapsed.Seconds(),
+    }
+    if stats.Hit + stats.Miss > 0 {
+        res.summary.HitRatio = float64(stats.Hit) / float64(stats.Hit + stats.Miss)
+    }
+    if warmup != nil && warmup.Done() {
+        warm := warmup.Stats(stats)
+        res.summary.Warm = &warmSummary{Requests: warmup.Requests(), Stats: warm}
+        if warm.Hit + warm.Miss > 0 {
+            res.summary.Warm.HitRatio = float64(warm.Hit) / float64(warm.Hit + warm.Miss)
+        }
+    }
+    if details, ok := sim.(simulator.Details); ok {
+        res.summary.Details = details.Details()
+    }
+    if tenants != nil {
+        for _, tenant := range tenants.Tenants() {
+            res.summary.Tenants = append(res.summary.Tenants, tenantSummary{
+                Tenant:     tenant,
+                Stats:      tenants.Get(tenant),
+                HitRatio:   tenants.HitRatio(tenant),
+            })
+        }
+        res.summary.Fairness = tenants.Fairness()
+    }
+
+    if recorder != nil {
+        recorder.Flush(sim)
+        res.err = writeCSV(
+            fmt.Sprintf("%v_%v_%d_windows.csv", opts.outPrefix, strings.ToLower(j.algorithm), j.cache),
+            recorder.PrintToFile,
+        )
+        if res.err != nil {
+            return res
+        }
+    }
+
+    if telemetry, ok := sim.(simulator.Telemetry); ok {
+        res.err = writeCSV(
+            fmt.Sprintf("%v_%v_%d_telemetry.csv", opts.outPrefix, strings.ToLower(j.algorithm), j.cache),
+            telemetry.PrintTelemetryToFile,
+        )
+    }
+
+    return res
+}
+
+// runMatrix runs the jobs on a pool of workers and writes their results to
+// out in job order, as soon as every preceding job has finished; the
+// structured results are stored in <outPrefix>_results.json
+func runMatrix(jobs []job, traces []simulator.Trace, opts runOptions, workers int, out io.Writer) (err error) {
+    var (
+        queue       chan job        = make(chan job)
+        results     chan *result    = make(chan *result)
+        pending     map[int]*result = make(map[int]*result)
+        summaries   []summary
+        wg          sync.WaitGroup
+        next        int
+    )
+
+    if workers < 1 {
+        workers = 1
+    }
+
+    for i := 0; i < workers; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for j := range queue {
+                results <- runJob(j, traces, opts)
+            }
+        }()
+    }
+
+    go func() {
+        for _, j := range jobs {
+            queue <- j
+        }
+        close(queue)
+        wg.Wait()
+        close(results)
+    }()
+
+    for res := range results {
+        fmt.Printf("%v %d: %.4fs\n", res.job.algorithm, res.job.cache, res.elapsed.Seconds())
+        pending[res.index] = res
+
+        for pending[next] != nil {
+            res = pending[next]
+            delete(pending, next)
+            next++
+
+            if res.err != nil && err == nil {
+                err = res.err
+            }
+            if err == nil {
+                _, err = out.Write(res.output.Bytes())
+                summaries = append(summaries, res.summary)
+            }
+        }
+    }
+
+    if err != nil {
+        return err
+    }
+
+    return writeJSON(opts.outPrefix + "_results.json", summaries)
+}
+
+// writeCSV stores a time series of a single run next to the result file
+func writeCSV(path string, print func(io.Writer) error) (err error) {
+    var file *os.File
+
+    file, err = os.Create(path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    return print(file)
+}
+
+// writeJSON stores the structured results of a run next to the result file
+func writeJSON(path string, value interface{}) (err error) {
+    var file *os.File
+
+    file, err = os.Create(path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    encoder := json.NewEncoder(file)
+    encoder.SetIndent("", "  ")
+    return encoder.Encode(value)
+}
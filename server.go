@@ -0,0 +1,117 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "log"
+    "os"
+    "os/signal"
+    "strings"
+    "syscall"
+
+    "github.com/mohammadtauchid/golang-cache/v2/cache"
+    "github.com/mohammadtauchid/golang-cache/v2/server"
+)
+
+// protocols lists the values of the -protocol option
+var protocols = []string{"resp", "memcached"}
+
+// daemon is a protocol server
+type daemon interface {
+    ListenAndServe(addr string) error
+    SetMaxItemSize(size int)
+    Close() error
+}
+
+// serve runs a cache daemon speaking the Redis or the memcached protocol,
+// backed by one of the policies; the cache can be saved on shutdown and
+// reloaded on startup
+func serve(args []string) {
+    var (
+        flags       *flag.FlagSet = flag.NewFlagSet("server", flag.ExitOnError)
+        protocol    string
+        addr        string
+        algorithm   string
+        size        int
+        snapshot    string
+        maxItemSize int
+        c           *cache.Cache
+        codec       cache.Codec
+        srv         daemon
+        err         error
+    )
+
+    flags.StringVar(&protocol, "protocol", "resp", fmt.Sprintf("protocol spoken to the clients, one of %v", protocols))
+    flags.StringVar(&addr, "addr", "", "address to listen on, 127.0.0.1:6379 for resp and 127.0.0.1:11211 for memcached by default")
+    flags.StringVar(&algorithm, "algorithm", "arc", fmt.Sprintf("replacement policy, one of %v", cache.Algorithms))
+    flags.IntVar(&size, "size", 100000, "number of cached keys")
+    flags.StringVar(&snapshot, "snapshot", "", "load the cache from this file on startup and save it on shutdown")
+    flags.IntVar(&maxItemSize, "max-item-size", server.DefaultMaxItemSize, "largest value in bytes a client may store")
+    flags.Usage = func() {
+        fmt.Println("Usage: ./main server [options]")
+        fmt.Println("Example: ./main server -algorithm marc -size 1000000 -addr 127.0.0.1:6379")
+        fmt.Println("         ./main server -protocol memcached -algorithm arc -size 1000000")
+        fmt.Println("Options:")
+        flags.PrintDefaults()
+    }
+    flags.Parse(args)
+
+    if flags.NArg() != 0 {
+        flags.Usage()
+        os.Exit(1)
+    }
+    if maxItemSize < 1 {
+        log.Fatalf("invalid item size %d", maxItemSize)
+    }
+
+    c, err = cache.New(algorithm, size)
+    if err != nil {
+        log.Fatal(err.Error())
+    }
+
+    switch strings.ToLower(protocol) {
+    case "resp":
+        if addr == "" {
+            addr = "127.0.0.1:6379"
+        }
+        codec, srv = cache.BytesCodec{}, server.NewRESP(c)
+    case "memcached":
+        if addr == "" {
+            addr = "127.0.0.1:11211"
+        }
+        codec, srv = server.ItemCodec{}, server.NewMemcached(c)
+    default:
+        log.Fatalf("unknown protocol %q, expected one of %v", protocol, protocols)
+    }
+    srv.SetMaxItemSize(maxItemSize)
+
+    if snapshot != "" {
+        err = c.Load(snapshot, codec)
+        if err == nil {
+            log.Printf("loaded %d keys from %v", c.Len(), snapshot)
+        } else if !errors.Is(err, os.ErrNotExist) {
+            // a damaged snapshot must not keep the server from starting
+            log.Printf("starting cold: %v", err)
+        }
+    }
+
+    go func() {
+        signals := make(chan os.Signal, 1)
+        signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+        <-signals
+        srv.Close()
+    }()
+
+    log.Printf("serving %v cache of %d keys to %v clients on %v", c.Algorithm(), size, strings.ToLower(protocol), addr)
+    if err = srv.ListenAndServe(addr); err != nil {
+        log.Fatal(err.Error())
+    }
+
+    if snapshot != "" {
+        if err = c.Save(snapshot, codec); err != nil {
+            log.Fatalf("cannot save the cache: %v", err)
+        }
+        log.Printf("saved %d keys to %v", c.Len(), snapshot)
+    }
+}
@@ -0,0 +1,618 @@
+package server
+
+import (
+    "bufio"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "os"
+    "strconv"
+    "strings"
+    "sync/atomic"
+    "time"
+
+    "github.com/mohammadtauchid/golang-cache/v2/cache"
+)
+
+const (
+    // maxKey is the longest key memcached accepts
+    maxKey = 250
+    // relativeExpiry is the largest expiration time taken as seconds from
+    // now; larger ones are unix timestamps
+    relativeExpiry = 60 * 60 * 24 * 30
+)
+
+// Version is reported by the version and stats commands
+const Version = "golang-cache-1.0"
+
+var (
+    errFormat   = errors.New("bad command line format")
+    errChunk    = errors.New("bad data chunk")
+    errTooLarge = errors.New("object too large for cache")
+)
+
+type (
+    // Item is a value stored by memcached clients
+    Item struct {
+        Flags   uint32
+        CAS     uint64
+        Data    []byte
+    }
+
+    // ItemCodec saves the items of a memcached cache in snapshots
+    ItemCodec struct{}
+
+    // Memcached serves a cache to memcached clients over the text protocol
+    // (get, gets, set, add, delete, touch, stats, version, flush_all, quit)
+    // and its meta commands (mg, ms, md, mn)
+    Memcached struct {
+        listener
+        cache       *cache.Cache
+        started     time.Time
+        cas         uint64
+        maxItem     int // bytes of a value
+
+        connections int64
+        cmdGet      int64
+        cmdSet      int64
+        cmdTouch    int64
+        deleteHits  int64
+        deleteMiss  int64
+        touchHits   int64
+        touchMiss   int64
+    }
+
+    // mcConn is a client connection
+    mcConn struct {
+        reader      *bufio.Reader
+        writer      *bufio.Writer
+        maxItem     int
+    }
+)
+
+func (ItemCodec) Name() string {
+    return "memcached"
+}
+
+func (ItemCodec) Encode(value interface{}) ([]byte, error) {
+    item, ok := value.(*Item)
+    if !ok {
+        return nil, fmt.Errorf("memcached codec cannot encode a %T value", value)
+    }
+
+    data := make([]byte, 12 + len(item.Data))
+    binary.LittleEndian.PutUint32(data, item.Flags)
+    binary.LittleEndian.PutUint64(data[4:], item.CAS)
+    copy(data[12:], item.Data)
+
+    return data, nil
+}
+
+func (ItemCodec) Decode(data []byte) (interface{}, error) {
+    if len(data) < 12 {
+        return nil, errors.New("truncated memcached item")
+    }
+
+    return &Item{
+        Flags:  binary.LittleEndian.Uint32(data),
+        CAS:    binary.LittleEndian.Uint64(data[4:]),
+        Data:   append([]byte(nil), data[12:]...),
+    }, nil
+}
+
+func NewMemcached(c *cache.Cache) *Memcached {
+    s := &Memcached{
+        cache:      c,
+        started:    time.Now(),
+        cas:        uint64(time.Now().UnixNano()), // unique across restarts
+        maxItem:    DefaultMaxItemSize,
+    }
+    s.listener = newListener(s.handle)
+
+    return s
+}
+
+// SetMaxItemSize bounds the size of the values; larger ones are answered
+// with SERVER_ERROR and their data is skipped
+func (s *Memcached) SetMaxItemSize(size int) {
+    s.maxItem = size
+}
+
+// expiry converts a memcached expiration time: seconds from now, a unix
+// timestamp past 30 days, 0 for no expiry, or negative for already expired
+func expiry(exptime int64) (ttl time.Duration, expired bool) {
+    switch {
+    case exptime < 0:
+        return 0, true
+    case exptime == 0:
+        return 0, false
+    case exptime > relativeExpiry:
+        ttl = time.Until(time.Unix(exptime, 0))
+        return ttl, ttl <= 0
+    default:
+        return time.Duration(exptime) * time.Second, false
+    }
+}
+
+func validKey(key string) bool {
+    if len(key) == 0 || len(key) > maxKey {
+        return false
+    }
+    for i := 0; i < len(key); i++ {
+        if key[i] <= ' ' || key[i] == 0x7f {
+            return false
+        }
+    }
+    return true
+}
+
+func (s *Memcached) handle(conn net.Conn) {
+    atomic.AddInt64(&s.connections, 1)
+    defer atomic.AddInt64(&s.connections, -1)
+
+    c := &mcConn{
+        reader:     bufio.NewReaderSize(conn, maxLine),
+        writer:     bufio.NewWriter(conn),
+        maxItem:    s.maxItem,
+    }
+
+    for {
+        line, err := readLine(c.reader)
+        if errors.Is(err, errLineTooLong) {
+            c.writer.WriteString("CLIENT_ERROR " + err.Error() + "\r\n")
+            c.writer.Flush()
+            return
+        }
+        if err != nil {
+            return
+        }
+        fields := strings.Fields(line)
+        if len(fields) == 0 {
+            c.writer.WriteString("ERROR\r\n")
+            c.writer.Flush()
+            continue
+        }
+
+        quit, err := s.execute(c, strings.ToLower(fields[0]), fields[1:])
+        switch {
+        case errors.Is(err, errFormat), errors.Is(err, errChunk):
+            c.writer.WriteString("CLIENT_ERROR " + err.Error() + "\r\n")
+        case errors.Is(err, errTooLarge):
+            c.writer.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+        case errors.Is(err, errLineTooLong):
+            c.writer.WriteString("CLIENT_ERROR " + err.Error() + "\r\n")
+            c.writer.Flush()
+            return
+        case err != nil:
+            // the connection broke while reading a data block
+            return
+        }
+
+        // replies are sent once the pipelined requests have been read
+        if c.reader.Buffered() == 0 || quit {
+            if err = c.writer.Flush(); err != nil {
+                return
+            }
+        }
+        if quit {
+            return
+        }
+    }
+}
+
+// execute runs a command, returning whether the connection must be closed
+func (s *Memcached) execute(c *mcConn, name string, args []string) (quit bool, err error) {
+    switch name {
+    case "get", "gets":
+        if len(args) == 0 {
+            return false, errFormat
+        }
+        for _, key := range args {
+            if !validKey(key) {
+                return false, errFormat
+            }
+        }
+        for _, key := range args {
+            atomic.AddInt64(&s.cmdGet, 1)
+            if item, ok := s.get(key); ok {
+                if name == "gets" {
+                    fmt.Fprintf(c.writer, "VALUE %s %d %d %d\r\n", key, item.Flags, len(item.Data), item.CAS)
+                } else {
+                    fmt.Fprintf(c.writer, "VALUE %s %d %d\r\n", key, item.Flags, len(item.Data))
+                }
+                c.writer.Write(item.Data)
+                c.writer.WriteString("\r\n")
+            }
+        }
+        c.writer.WriteString("END\r\n")
+
+    case "set", "add":
+        return false, s.store(c, name, args)
+
+    case "delete":
+        if len(args) < 1 || len(args) > 2 || !validKey(args[0]) {
+            return false, errFormat
+        }
+        noreply := len(args) == 2 && args[1] == "noreply"
+        if s.cache.Delete(args[0]) {
+            atomic.AddInt64(&s.deleteHits, 1)
+            c.reply(noreply, "DELETED")
+        } else {
+            atomic.AddInt64(&s.deleteMiss, 1)
+            c.reply(noreply, "NOT_FOUND")
+        }
+
+    case "touch":
+        if len(args) < 2 || len(args) > 3 || !validKey(args[0]) {
+            return false, errFormat
+        }
+        exptime, err := strconv.ParseInt(args[1], 10, 64)
+        if err != nil {
+            return false, errFormat
+        }
+        noreply := len(args) == 3 && args[2] == "noreply"
+        atomic.AddInt64(&s.cmdTouch, 1)
+        if s.touch(args[0], exptime) {
+            atomic.AddInt64(&s.touchHits, 1)
+            c.reply(noreply, "TOUCHED")
+        } else {
+            atomic.AddInt64(&s.touchMiss, 1)
+            c.reply(noreply, "NOT_FOUND")
+        }
+
+    case "stats":
+        if len(args) == 0 {
+            s.stats(c.writer)
+        }
+        c.writer.WriteString("END\r\n")
+
+    case "flush_all":
+        s.cache.Flush()
+        c.reply(len(args) > 0 && args[len(args) - 1] == "noreply", "OK")
+
+    case "version":
+        c.writer.WriteString("VERSION " + Version + "\r\n")
+
+    case "quit":
+        return true, nil
+
+    case "mg":
+        return false, s.metaGet(c, args)
+
+    case "ms":
+        return false, s.metaSet(c, args)
+
+    case "md":
+        return false, s.metaDelete(c, args)
+
+    case "mn":
+        c.writer.WriteString("MN\r\n")
+
+    default:
+        c.writer.WriteString("ERROR\r\n")
+    }
+
+    return false, nil
+}
+
+// get returns the item of key, counting a hit or a miss
+func (s *Memcached) get(key string) (item *Item, ok bool) {
+    value, ok := s.cache.Get(key)
+    if !ok {
+        return nil, false
+    }
+    return value.(*Item), true
+}
+
+// touch sets the expiration time of key
+func (s *Memcached) touch(key string, exptime int64) bool {
+    ttl, expired := expiry(exptime)
+    if expired {
+        return s.cache.Delete(key)
+    }
+    return s.cache.Touch(key, ttl)
+}
+
+// put stores an item, or only adds it when add is set, returning whether
+// it was stored. Sets refused by the filters of larc and marc count as
+// stored, as if they were evicted right away; adds report them.
+func (s *Memcached) put(key string, item *Item, exptime int64, add bool) (stored bool) {
+    atomic.AddInt64(&s.cmdSet, 1)
+
+    ttl, expired := expiry(exptime)
+    if add && s.cache.Contains(key) {
+        return false
+    }
+    if expired {
+        s.cache.Delete(key)
+        return true
+    }
+
+    item.CAS = atomic.AddUint64(&s.cas, 1)
+    if add {
+        // the key may have been added meanwhile
+        return s.cache.Add(key, item, ttl)
+    }
+    s.cache.SetWithTTL(key, item, ttl)
+
+    return true
+}
+
+// readData reads the data block of a storage command, skipping it without
+// allocating when it is larger than an item
+func (c *mcConn) readData(size int) (data []byte, err error) {
+    if size > c.maxItem {
+        if _, err = io.CopyN(io.Discard, c.reader, int64(size) + 2); err != nil {
+            return nil, err
+        }
+        return nil, errTooLarge
+    }
+
+    data = make([]byte, size + 2)
+    if _, err = io.ReadFull(c.reader, data); err != nil {
+        return nil, err
+    }
+    if string(data[size:]) != "\r\n" {
+        // skip the rest of the line, as memcached does
+        if _, err = readLine(c.reader); errors.Is(err, errLineTooLong) {
+            return nil, err
+        }
+        return nil, errChunk
+    }
+    return data[:size], nil
+}
+
+// store handles set and add: <key> <flags> <exptime> <bytes> [noreply]
+func (s *Memcached) store(c *mcConn, name string, args []string) (err error) {
+    if len(args) < 4 || len(args) > 5 || !validKey(args[0]) {
+        return errFormat
+    }
+
+    flags, err1 := strconv.ParseUint(args[1], 10, 32)
+    exptime, err2 := strconv.ParseInt(args[2], 10, 64)
+    size, err3 := strconv.Atoi(args[3])
+    if err1 != nil || err2 != nil || err3 != nil || size < 0 {
+        return errFormat
+    }
+    noreply := len(args) == 5 && args[4] == "noreply"
+
+    data, err := c.readData(size)
+    if err != nil {
+        return err
+    }
+
+    if s.put(args[0], &Item{Flags: uint32(flags), Data: data}, exptime, name == "add") {
+        c.reply(noreply, "STORED")
+    } else {
+        c.reply(noreply, "NOT_STORED")
+    }
+
+    return nil
+}
+
+func (c *mcConn) reply(noreply bool, s string) {
+    if !noreply {
+        c.writer.WriteString(s + "\r\n")
+    }
+}
+
+// metaFlags splits the flags of a meta command into the flag characters
+// and their tokens
+func metaFlags(args []string) (flags map[byte]string, err error) {
+    flags = make(map[byte]string)
+    for _, arg := range args {
+        if len(arg) == 0 {
+            return nil, errFormat
+        }
+        flags[arg[0]] = arg[1:]
+    }
+    return flags, nil
+}
+
+// metaReturn renders the return flags shared by the meta commands
+func metaReturn(flags map[byte]string, key string, item *Item, ttl time.Duration, hasTTL bool) string {
+    var b strings.Builder
+
+    for _, flag := range []byte("Okfcst") {
+        token, ok := flags[flag]
+        if !ok {
+            continue
+        }
+        switch {
+        case flag == 'O':
+            fmt.Fprintf(&b, " O%s", token)
+        case flag == 'k':
+            fmt.Fprintf(&b, " k%s", key)
+        case item == nil:
+        case flag == 'f':
+            fmt.Fprintf(&b, " f%d", item.Flags)
+        case flag == 'c':
+            fmt.Fprintf(&b, " c%d", item.CAS)
+        case flag == 's':
+            fmt.Fprintf(&b, " s%d", len(item.Data))
+        case flag == 't' && !hasTTL:
+            b.WriteString(" t-1")
+        case flag == 't':
+            fmt.Fprintf(&b, " t%d", int64((ttl + time.Second - 1) / time.Second))
+        }
+    }
+
+    return b.String()
+}
+
+// metaGet handles mg <key> <flags>*, supporting the v, k, f, c, s, t, q,
+// O and T flags
+func (s *Memcached) metaGet(c *mcConn, args []string) (err error) {
+    if len(args) < 1 || !validKey(args[0]) {
+        return errFormat
+    }
+    key := args[0]
+    flags, err := metaFlags(args[1:])
+    if err != nil {
+        return err
+    }
+    for flag := range flags {
+        if !strings.ContainsRune("vkfcstqOT", rune(flag)) {
+            return fmt.Errorf("%w: invalid flag", errFormat)
+        }
+    }
+
+    atomic.AddInt64(&s.cmdGet, 1)
+    item, ok := s.get(key)
+    if !ok {
+        if _, quiet := flags['q']; !quiet {
+            c.writer.WriteString("EN\r\n")
+        }
+        return nil
+    }
+
+    if token, touch := flags['T']; touch {
+        exptime, err := strconv.ParseInt(token, 10, 64)
+        if err != nil {
+            return errFormat
+        }
+        atomic.AddInt64(&s.cmdTouch, 1)
+        s.touch(key, exptime)
+    }
+    ttl, _ := s.cache.TTL(key)
+
+    ret := metaReturn(flags, key, item, ttl, ttl > 0)
+    if _, value := flags['v']; value {
+        fmt.Fprintf(c.writer, "VA %d%s\r\n", len(item.Data), ret)
+        c.writer.Write(item.Data)
+        c.writer.WriteString("\r\n")
+    } else {
+        fmt.Fprintf(c.writer, "HD%s\r\n", ret)
+    }
+
+    return nil
+}
+
+// metaSet handles ms <key> <datalen> <flags>*, supporting the F, T, q, O,
+// k and M (E for add, S for set) flags
+func (s *Memcached) metaSet(c *mcConn, args []string) (err error) {
+    if len(args) < 2 || !validKey(args[0]) {
+        return errFormat
+    }
+    key := args[0]
+    size, err := strconv.Atoi(args[1])
+    if err != nil || size < 0 {
+        return errFormat
+    }
+
+    flags, err := metaFlags(args[2:])
+    if err != nil {
+        return err
+    }
+    data, err := c.readData(size)
+    if err != nil {
+        return err
+    }
+
+    item := &Item{Data: data}
+    var exptime int64
+    for flag, token := range flags {
+        switch flag {
+        case 'F':
+            value, err := strconv.ParseUint(token, 10, 32)
+            if err != nil {
+                return errFormat
+            }
+            item.Flags = uint32(value)
+        case 'T':
+            if exptime, err = strconv.ParseInt(token, 10, 64); err != nil {
+                return errFormat
+            }
+        case 'M':
+            if token != "E" && token != "S" && token != "e" && token != "s" {
+                return fmt.Errorf("%w: invalid mode", errFormat)
+            }
+        case 'q', 'O', 'k':
+        default:
+            return fmt.Errorf("%w: invalid flag", errFormat)
+        }
+    }
+
+    add := strings.EqualFold(flags['M'], "E")
+    _, quiet := flags['q']
+    ret := metaReturn(flags, key, nil, 0, false)
+    if s.put(key, item, exptime, add) {
+        if !quiet {
+            fmt.Fprintf(c.writer, "HD%s\r\n", ret)
+        }
+    } else {
+        fmt.Fprintf(c.writer, "NS%s\r\n", ret)
+    }
+
+    return nil
+}
+
+// metaDelete handles md <key> <flags>*, supporting the q, O and k flags
+func (s *Memcached) metaDelete(c *mcConn, args []string) (err error) {
+    if len(args) < 1 || !validKey(args[0]) {
+        return errFormat
+    }
+    key := args[0]
+    flags, err := metaFlags(args[1:])
+    if err != nil {
+        return err
+    }
+    for flag := range flags {
+        if !strings.ContainsRune("qOk", rune(flag)) {
+            return fmt.Errorf("%w: invalid flag", errFormat)
+        }
+    }
+
+    _, quiet := flags['q']
+    ret := metaReturn(flags, key, nil, 0, false)
+    if s.cache.Delete(key) {
+        atomic.AddInt64(&s.deleteHits, 1)
+        if !quiet {
+            fmt.Fprintf(c.writer, "HD%s\r\n", ret)
+        }
+    } else {
+        atomic.AddInt64(&s.deleteMiss, 1)
+        fmt.Fprintf(c.writer, "NF%s\r\n", ret)
+    }
+
+    return nil
+}
+
+// stats writes the general statistics, followed by those of the policy
+func (s *Memcached) stats(w io.Writer) {
+    stats := s.cache.Stats()
+    policy := s.cache.PolicyStats()
+    ratio := 0.0
+    if policy.Hit + policy.Miss > 0 {
+        ratio = float64(policy.Hit) / float64(policy.Hit + policy.Miss)
+    }
+
+    fmt.Fprintf(w, "STAT pid %d\r\n", os.Getpid())
+    fmt.Fprintf(w, "STAT uptime %d\r\n", int64(time.Since(s.started).Seconds()))
+    fmt.Fprintf(w, "STAT time %d\r\n", time.Now().Unix())
+    fmt.Fprintf(w, "STAT version %s\r\n", Version)
+    fmt.Fprintf(w, "STAT curr_connections %d\r\n", atomic.LoadInt64(&s.connections))
+    fmt.Fprintf(w, "STAT cmd_get %d\r\n", atomic.LoadInt64(&s.cmdGet))
+    fmt.Fprintf(w, "STAT cmd_set %d\r\n", atomic.LoadInt64(&s.cmdSet))
+    fmt.Fprintf(w, "STAT cmd_touch %d\r\n", atomic.LoadInt64(&s.cmdTouch))
+    fmt.Fprintf(w, "STAT get_hits %d\r\n", stats.Hit)
+    fmt.Fprintf(w, "STAT get_misses %d\r\n", stats.Miss)
+    fmt.Fprintf(w, "STAT get_expired %d\r\n", s.cache.Expired())
+    fmt.Fprintf(w, "STAT delete_hits %d\r\n", atomic.LoadInt64(&s.deleteHits))
+    fmt.Fprintf(w, "STAT delete_misses %d\r\n", atomic.LoadInt64(&s.deleteMiss))
+    fmt.Fprintf(w, "STAT touch_hits %d\r\n", atomic.LoadInt64(&s.touchHits))
+    fmt.Fprintf(w, "STAT touch_misses %d\r\n", atomic.LoadInt64(&s.touchMiss))
+    fmt.Fprintf(w, "STAT curr_items %d\r\n", stats.Occupancy)
+    fmt.Fprintf(w, "STAT limit_items %d\r\n", stats.Capacity)
+    fmt.Fprintf(w, "STAT evictions %d\r\n", stats.Eviction)
+    fmt.Fprintf(w, "STAT policy %s\r\n", s.cache.Algorithm())
+    fmt.Fprintf(w, "STAT policy_items %d\r\n", policy.Occupancy)
+    fmt.Fprintf(w, "STAT policy_hits %d\r\n", policy.Hit)
+    fmt.Fprintf(w, "STAT policy_misses %d\r\n", policy.Miss)
+    fmt.Fprintf(w, "STAT policy_hit_ratio %.4f\r\n", ratio)
+    fmt.Fprintf(w, "STAT policy_writes %d\r\n", policy.Write)
+    fmt.Fprintf(w, "STAT policy_evictions %d\r\n", policy.Eviction)
+    fmt.Fprintf(w, "STAT policy_deletes %d\r\n", policy.Delete)
+}
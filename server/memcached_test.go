@@ -0,0 +1,208 @@
+package server
+
+import (
+    "bufio"
+    "fmt"
+    "net"
+    "strings"
+    "testing"
+
+    "github.com/mohammadtauchid/golang-cache/v2/cache"
+)
+
+func newMemcached(c *cache.Cache) testServer {
+    return NewMemcached(c)
+}
+
+func TestMemcachedSetAddGet(t *testing.T) {
+    conn, reader := start(t, newMemcached)
+
+    send(t, conn, "set foo 5 0 3\r\nbar\r\n")
+    expect(t, reader, "STORED")
+    send(t, conn, "add foo 0 0 3\r\nbaz\r\n")
+    expect(t, reader, "NOT_STORED")
+    send(t, conn, "add new 0 0 1\r\nx\r\n")
+    expect(t, reader, "STORED")
+
+    send(t, conn, "get foo new missing\r\n")
+    expect(t, reader, "VALUE foo 5 3", "bar", "VALUE new 0 1", "x", "END")
+
+    send(t, conn, "gets foo\r\n")
+    line, err := reader.ReadString('\n')
+    if err != nil || !strings.HasPrefix(line, "VALUE foo 5 3 ") {
+        t.Fatalf("got %q, %v, expected the value with its cas", line, err)
+    }
+    expect(t, reader, "bar", "END")
+
+    send(t, conn, "delete foo\r\n", "delete foo\r\n", "get foo\r\n")
+    expect(t, reader, "DELETED", "NOT_FOUND", "END")
+}
+
+func TestMemcachedNoreply(t *testing.T) {
+    conn, reader := start(t, newMemcached)
+
+    send(t, conn, "set foo 0 0 3 noreply\r\nbar\r\n", "add foo 0 0 3 noreply\r\nbaz\r\n", "get foo\r\n")
+    expect(t, reader, "VALUE foo 0 3", "bar", "END")
+
+    send(t, conn, "delete foo noreply\r\n", "version\r\n")
+    expect(t, reader, "VERSION " + Version)
+}
+
+func TestMemcachedMeta(t *testing.T) {
+    conn, reader := start(t, newMemcached)
+
+    send(t, conn, "ms foo 2 F7 T0\r\nhi\r\n")
+    expect(t, reader, "HD")
+    send(t, conn, "mg foo v k f s t\r\n")
+    expect(t, reader, "VA 2 kfoo f7 s2 t-1", "hi")
+    send(t, conn, "mg foo Oabc\r\n")
+    expect(t, reader, "HD Oabc")
+
+    send(t, conn, "ms foo 2 ME\r\nho\r\n")
+    expect(t, reader, "NS")
+    send(t, conn, "ms bar 2 ME q\r\nho\r\n", "mg missing v q\r\n", "mn\r\n")
+    expect(t, reader, "MN")
+    send(t, conn, "mg missing v\r\n")
+    expect(t, reader, "EN")
+
+    send(t, conn, "md bar\r\n", "md bar\r\n")
+    expect(t, reader, "HD", "NF")
+    send(t, conn, "mg foo x\r\n")
+    expect(t, reader, "CLIENT_ERROR bad command line format: invalid flag")
+}
+
+func TestMemcachedBadChunk(t *testing.T) {
+    conn, reader := start(t, newMemcached)
+
+    // the rest of the line is skipped and the connection stays usable
+    send(t, conn, "set foo 0 0 3\r\nbarbaz\r\n", "get foo\r\n")
+    expect(t, reader, "CLIENT_ERROR bad data chunk", "END")
+
+    send(t, conn, "set foo 0 0 bar\r\n")
+    expect(t, reader, "CLIENT_ERROR bad command line format")
+}
+
+func TestMemcachedLineTooLong(t *testing.T) {
+    conn, reader := start(t, newMemcached)
+
+    send(t, conn, "get " + strings.Repeat("a", maxLine - 4))
+    expect(t, reader, "CLIENT_ERROR line too long")
+    expectClosed(t, reader)
+}
+
+func TestMemcachedTooLarge(t *testing.T) {
+    conn, reader := start(t, func(c *cache.Cache) testServer {
+        s := NewMemcached(c)
+        s.SetMaxItemSize(4)
+        return s
+    })
+
+    // the data is skipped and the connection stays usable
+    send(t, conn, "set foo 0 0 5\r\nhello\r\n", "ms foo 5\r\nhello\r\n", "set foo 0 0 4\r\nhell\r\n", "get foo\r\n")
+    expect(t, reader,
+        "SERVER_ERROR object too large for cache",
+        "SERVER_ERROR object too large for cache",
+        "STORED", "VALUE foo 0 4", "hell", "END")
+}
+
+func TestMemcachedKeys(t *testing.T) {
+    conn, reader := start(t, newMemcached)
+
+    send(t, conn, "set foo 0 0 1\r\nx\r\n")
+    expect(t, reader, "STORED")
+
+    for _, request := range []string{
+        "get foo " + strings.Repeat("k", maxKey + 1) + "\r\n",
+        "gets foo bad\x01key\r\n",
+        "delete bad\x7fkey\r\n",
+        "touch " + strings.Repeat("k", maxKey + 1) + " 10\r\n",
+    } {
+        send(t, conn, request)
+        expect(t, reader, "CLIENT_ERROR bad command line format")
+    }
+
+    send(t, conn, "get " + strings.Repeat("k", maxKey) + " foo\r\n")
+    expect(t, reader, "VALUE foo 0 1", "x", "END")
+}
+
+func TestMemcachedTouch(t *testing.T) {
+    conn, reader := start(t, newMemcached)
+
+    send(t, conn, "set foo 0 0 1\r\nx\r\n", "set bar 0 100 1\r\ny\r\n")
+    expect(t, reader, "STORED", "STORED")
+
+    send(t, conn, "touch foo 100\r\n", "touch missing 100\r\n")
+    expect(t, reader, "TOUCHED", "NOT_FOUND")
+    send(t, conn, "mg foo t\r\n")
+    expect(t, reader, "HD t100")
+
+    // a negative expiration time expires the item at once
+    send(t, conn, "touch bar -1\r\n", "get bar\r\n")
+    expect(t, reader, "TOUCHED", "END")
+    send(t, conn, "touch foo 0 noreply\r\n", "mg foo t\r\n")
+    expect(t, reader, "HD t-1")
+}
+
+// stats sends stats and returns its fields
+func stats(t *testing.T, conn net.Conn, reader *bufio.Reader) map[string]string {
+    t.Helper()
+
+    send(t, conn, "stats\r\n")
+    fields := make(map[string]string)
+    for {
+        line, err := reader.ReadString('\n')
+        if err != nil {
+            t.Fatal(err)
+        }
+        line = strings.TrimRight(line, "\r\n")
+        if line == "END" {
+            return fields
+        }
+        parts := strings.SplitN(line, " ", 3)
+        if len(parts) != 3 || parts[0] != "STAT" {
+            t.Fatalf("got %q, expected a STAT line", line)
+        }
+        fields[parts[1]] = parts[2]
+    }
+}
+
+func TestMemcachedStats(t *testing.T) {
+    conn, reader := start(t, newMemcached)
+
+    // 105 keys in a cache of 100 evict the first 5
+    for i := 0; i < 105; i++ {
+        send(t, conn, fmt.Sprintf("set key%d 0 0 1\r\nx\r\n", i))
+        expect(t, reader, "STORED")
+    }
+    send(t, conn, "get key104 key100 key0\r\n")
+    expect(t, reader, "VALUE key104 0 1", "x", "VALUE key100 0 1", "x", "END")
+    send(t, conn, "delete key104\r\n", "delete key0\r\n", "touch key100 10\r\n")
+    expect(t, reader, "DELETED", "NOT_FOUND", "TOUCHED")
+
+    fields := stats(t, conn, reader)
+    for name, expected := range map[string]string{
+        "curr_connections": "1",
+        "cmd_get":          "3",
+        "cmd_set":          "105",
+        "cmd_touch":        "1",
+        "get_hits":         "2",
+        "get_misses":       "1",
+        "delete_hits":      "1",
+        "delete_misses":    "1",
+        "touch_hits":       "1",
+        "curr_items":       "99",
+        "limit_items":      "100",
+        "evictions":        "5",
+        "policy":           "lru",
+        "policy_items":     "99",
+        "policy_hits":      "2",
+        "policy_misses":    "105", // the first write of every key
+        "policy_writes":    "105",
+        "policy_evictions": "5",
+        "policy_deletes":   "1",
+    } {
+        if fields[name] != expected {
+            t.Errorf("%v: got %q, expected %q", name, fields[name], expected)
+        }
+    }
+}
@@ -0,0 +1,314 @@
+package server
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/mohammadtauchid/golang-cache/v2/cache"
+)
+
+// errProtocol is returned for malformed requests; the connection is closed
+var errProtocol = errors.New("protocol error")
+
+type (
+    // RESP serves a cache to Redis clients, speaking the subset of the
+    // protocol needed for GET, SET with EX or PX, DEL, EXISTS, INFO and
+    // FLUSHALL, plus PING and QUIT
+    RESP struct {
+        listener
+        cache       *cache.Cache
+        started     time.Time
+        maxBulk     int // bytes of an argument
+    }
+
+    // respConn is a client connection
+    respConn struct {
+        reader      *bufio.Reader
+        writer      *bufio.Writer
+        maxBulk     int
+    }
+)
+
+func NewRESP(c *cache.Cache) *RESP {
+    s := &RESP{
+        cache:      c,
+        started:    time.Now(),
+        maxBulk:    DefaultMaxItemSize,
+    }
+    s.listener = newListener(s.handle)
+
+    return s
+}
+
+// SetMaxItemSize bounds the size of the arguments of a request, and thus
+// of the values; longer ones are protocol errors
+func (s *RESP) SetMaxItemSize(size int) {
+    s.maxBulk = size
+}
+
+func (s *RESP) handle(conn net.Conn) {
+    c := &respConn{
+        reader:     bufio.NewReaderSize(conn, maxLine),
+        writer:     bufio.NewWriter(conn),
+        maxBulk:    s.maxBulk,
+    }
+
+    for {
+        args, err := c.readCommand()
+        if err != nil {
+            if errors.Is(err, errProtocol) {
+                c.writeError("ERR " + err.Error())
+                c.writer.Flush()
+            }
+            return
+        }
+        if len(args) == 0 {
+            continue
+        }
+
+        quit := s.execute(c, args)
+
+        // replies are sent once the pipelined requests have been read
+        if c.reader.Buffered() == 0 || quit {
+            if err = c.writer.Flush(); err != nil {
+                return
+            }
+        }
+        if quit {
+            return
+        }
+    }
+}
+
+// execute runs a command, returning whether the connection must be closed
+func (s *RESP) execute(c *respConn, args []string) (quit bool) {
+    name := strings.ToUpper(args[0])
+    args = args[1:]
+
+    switch name {
+    case "PING":
+        if len(args) > 1 {
+            c.writeArity(name)
+        } else if len(args) == 1 {
+            c.writeBulk([]byte(args[0]))
+        } else {
+            c.writeSimple("PONG")
+        }
+
+    case "QUIT":
+        c.writeSimple("OK")
+        return true
+
+    case "GET":
+        if len(args) != 1 {
+            c.writeArity(name)
+            break
+        }
+        value, ok := s.cache.Get(args[0])
+        if !ok {
+            c.writeNull()
+            break
+        }
+        c.writeBulk(value.([]byte))
+
+    case "SET":
+        s.set(c, args)
+
+    case "DEL":
+        if len(args) < 1 {
+            c.writeArity(name)
+            break
+        }
+        deleted := 0
+        for _, key := range args {
+            if s.cache.Delete(key) {
+                deleted++
+            }
+        }
+        c.writeInteger(deleted)
+
+    case "EXISTS":
+        if len(args) < 1 {
+            c.writeArity(name)
+            break
+        }
+        exists := 0
+        for _, key := range args {
+            if s.cache.Contains(key) {
+                exists++
+            }
+        }
+        c.writeInteger(exists)
+
+    case "INFO":
+        c.writeBulk([]byte(s.info()))
+
+    case "FLUSHALL", "FLUSHDB":
+        s.cache.Flush()
+        c.writeSimple("OK")
+
+    case "COMMAND":
+        // sent by redis-cli on connection
+        c.writeArray(0)
+
+    default:
+        c.writeError(fmt.Sprintf("ERR unknown command '%v'", strings.ToLower(name)))
+    }
+
+    return false
+}
+
+// set handles SET key value [EX seconds | PX milliseconds]
+func (s *RESP) set(c *respConn, args []string) {
+    var ttl time.Duration
+
+    if len(args) < 2 {
+        c.writeArity("SET")
+        return
+    }
+
+    for i := 2; i < len(args); i++ {
+        option := strings.ToUpper(args[i])
+        if (option != "EX" && option != "PX") || ttl != 0 || i + 1 == len(args) {
+            c.writeError("ERR syntax error")
+            return
+        }
+        i++
+
+        n, err := strconv.ParseInt(args[i], 10, 64)
+        if err != nil || n <= 0 {
+            c.writeError("ERR invalid expire time in 'set' command")
+            return
+        }
+        if option == "EX" {
+            ttl = time.Duration(n) * time.Second
+        } else {
+            ttl = time.Duration(n) * time.Millisecond
+        }
+    }
+
+    // the filters of larc and marc may refuse the key; like an eviction
+    // right after the write, this is not an error
+    s.cache.SetWithTTL(args[0], []byte(args[1]), ttl)
+    c.writeSimple("OK")
+}
+
+// info renders the INFO reply with the counters of the cache and those
+// of its policy
+func (s *RESP) info() string {
+    var b strings.Builder
+
+    stats := s.cache.Stats()
+    policy := s.cache.PolicyStats()
+    ratio := 0.0
+    if policy.Hit + policy.Miss > 0 {
+        ratio = float64(policy.Hit) / float64(policy.Hit + policy.Miss)
+    }
+
+    fmt.Fprintf(&b, "# Server\r\n")
+    fmt.Fprintf(&b, "uptime_in_seconds:%d\r\n", int(time.Since(s.started).Seconds()))
+    fmt.Fprintf(&b, "\r\n# Stats\r\n")
+    fmt.Fprintf(&b, "keyspace_hits:%d\r\n", stats.Hit)
+    fmt.Fprintf(&b, "keyspace_misses:%d\r\n", stats.Miss)
+    fmt.Fprintf(&b, "evicted_keys:%d\r\n", stats.Eviction)
+    fmt.Fprintf(&b, "expired_keys:%d\r\n", s.cache.Expired())
+    fmt.Fprintf(&b, "\r\n# Policy\r\n")
+    fmt.Fprintf(&b, "policy:%v\r\n", s.cache.Algorithm())
+    fmt.Fprintf(&b, "policy_capacity:%d\r\n", policy.Capacity)
+    fmt.Fprintf(&b, "policy_keys:%d\r\n", policy.Occupancy)
+    fmt.Fprintf(&b, "policy_hits:%d\r\n", policy.Hit)
+    fmt.Fprintf(&b, "policy_misses:%d\r\n", policy.Miss)
+    fmt.Fprintf(&b, "policy_hit_ratio:%.4f\r\n", ratio)
+    fmt.Fprintf(&b, "policy_writes:%d\r\n", policy.Write)
+    fmt.Fprintf(&b, "policy_evictions:%d\r\n", policy.Eviction)
+    fmt.Fprintf(&b, "policy_deletes:%d\r\n", policy.Delete)
+
+    return b.String()
+}
+
+// readCommand reads a request: an array of bulk strings, or an inline
+// command as typed in telnet
+func (c *respConn) readCommand() (args []string, err error) {
+    line, err := c.readLine()
+    if err != nil {
+        return nil, err
+    }
+
+    if len(line) == 0 || line[0] != '*' {
+        return strings.Fields(line), nil
+    }
+
+    n, err := strconv.Atoi(line[1:])
+    if err != nil || n > 1024 * 1024 {
+        return nil, fmt.Errorf("%w: invalid multibulk length", errProtocol)
+    }
+
+    for i := 0; i < n; i++ {
+        line, err = c.readLine()
+        if err != nil {
+            return nil, err
+        }
+        if len(line) == 0 || line[0] != '$' {
+            return nil, fmt.Errorf("%w: expected '$', got '%.1s'", errProtocol, line)
+        }
+        size, err := strconv.Atoi(line[1:])
+        if err != nil || size < 0 || size > c.maxBulk {
+            return nil, fmt.Errorf("%w: invalid bulk length", errProtocol)
+        }
+
+        data := make([]byte, size + 2)
+        if _, err = io.ReadFull(c.reader, data); err != nil {
+            return nil, err
+        }
+        if string(data[size:]) != "\r\n" {
+            return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", errProtocol)
+        }
+        args = append(args, string(data[:size]))
+    }
+
+    return args, nil
+}
+
+func (c *respConn) readLine() (line string, err error) {
+    line, err = readLine(c.reader)
+    if errors.Is(err, errLineTooLong) {
+        return "", fmt.Errorf("%w: %v", errProtocol, err)
+    }
+    return line, err
+}
+
+func (c *respConn) writeSimple(s string) {
+    c.writer.WriteString("+" + s + "\r\n")
+}
+
+func (c *respConn) writeError(s string) {
+    c.writer.WriteString("-" + s + "\r\n")
+}
+
+func (c *respConn) writeArity(name string) {
+    c.writeError(fmt.Sprintf("ERR wrong number of arguments for '%v' command", strings.ToLower(name)))
+}
+
+func (c *respConn) writeInteger(n int) {
+    fmt.Fprintf(c.writer, ":%d\r\n", n)
+}
+
+func (c *respConn) writeBulk(data []byte) {
+    fmt.Fprintf(c.writer, "$%d\r\n", len(data))
+    c.writer.Write(data)
+    c.writer.WriteString("\r\n")
+}
+
+func (c *respConn) writeNull() {
+    c.writer.WriteString("$-1\r\n")
+}
+
+func (c *respConn) writeArray(n int) {
+    fmt.Fprintf(c.writer, "*%d\r\n", n)
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "net"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/mohammadtauchid/golang-cache/v2/cache"
+)
+
+func newRESP(c *cache.Cache) testServer {
+    return NewRESP(c)
+}
+
+func TestRESPSetGetDel(t *testing.T) {
+    conn, reader := start(t, newRESP)
+
+    send(t, conn, "*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$3\r\nbar\r\n")
+    expect(t, reader, "+OK")
+    send(t, conn, "*2\r\n$3\r\nGET\r\n$3\r\nfoo\r\n")
+    expect(t, reader, "$3", "bar")
+    send(t, conn, "*2\r\n$3\r\nGET\r\n$7\r\nmissing\r\n")
+    expect(t, reader, "$-1")
+
+    send(t, conn, "*3\r\n$6\r\nEXISTS\r\n$3\r\nfoo\r\n$7\r\nmissing\r\n")
+    expect(t, reader, ":1")
+    send(t, conn, "*3\r\n$3\r\nDEL\r\n$3\r\nfoo\r\n$7\r\nmissing\r\n")
+    expect(t, reader, ":1")
+    send(t, conn, "*2\r\n$3\r\nGET\r\n$3\r\nfoo\r\n")
+    expect(t, reader, "$-1")
+
+    send(t, conn, "*1\r\n$3\r\nGET\r\n")
+    expect(t, reader, "-ERR wrong number of arguments for 'get' command")
+}
+
+func TestRESPExpiry(t *testing.T) {
+    conn, reader := start(t, newRESP)
+
+    send(t, conn, "*5\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$3\r\nbar\r\n$2\r\nPX\r\n$2\r\n50\r\n")
+    expect(t, reader, "+OK")
+    send(t, conn, "*2\r\n$3\r\nGET\r\n$3\r\nfoo\r\n")
+    expect(t, reader, "$3", "bar")
+
+    time.Sleep(100 * time.Millisecond)
+    send(t, conn, "*2\r\n$3\r\nGET\r\n$3\r\nfoo\r\n")
+    expect(t, reader, "$-1")
+
+    send(t, conn, "*5\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$3\r\nbar\r\n$2\r\nEX\r\n$1\r\n0\r\n")
+    expect(t, reader, "-ERR invalid expire time in 'set' command")
+}
+
+func TestRESPPipelining(t *testing.T) {
+    conn, reader := start(t, newRESP)
+
+    send(t, conn,
+        "*1\r\n$4\r\nPING\r\n",
+        "*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n",
+        "*3\r\n$3\r\nSET\r\n$1\r\nb\r\n$1\r\n2\r\n",
+        "*2\r\n$3\r\nGET\r\n$1\r\na\r\n",
+        "*2\r\n$3\r\nGET\r\n$1\r\nb\r\n",
+        "PING inline\r\n",
+        "*1\r\n$4\r\nQUIT\r\n")
+    expect(t, reader, "+PONG", "+OK", "+OK", "$1", "1", "$1", "2", "$6", "inline", "+OK")
+    expectClosed(t, reader)
+}
+
+func TestRESPLineTooLong(t *testing.T) {
+    conn, reader := start(t, newRESP)
+
+    send(t, conn, "*" + strings.Repeat("1", maxLine))
+    expect(t, reader, "-ERR protocol error: line too long")
+    expectClosed(t, reader)
+}
+
+func TestRESPBulkTooLarge(t *testing.T) {
+    conn, reader := start(t, func(c *cache.Cache) testServer {
+        s := NewRESP(c)
+        s.SetMaxItemSize(16)
+        return s
+    })
+
+    send(t, conn, "*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$16\r\n0123456789abcdef\r\n")
+    expect(t, reader, "+OK")
+
+    // the announced length is refused before anything is allocated
+    send(t, conn, "*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$536870000\r\n")
+    expect(t, reader, "-ERR protocol error: invalid bulk length")
+    expectClosed(t, reader)
+}
+
+// info sends INFO and returns its fields
+func info(t *testing.T, conn net.Conn, reader *bufio.Reader) map[string]string {
+    t.Helper()
+
+    send(t, conn, "*1\r\n$4\r\nINFO\r\n")
+    line, err := reader.ReadString('\n')
+    if err != nil || !strings.HasPrefix(line, "$") {
+        t.Fatalf("got %q, %v, expected a bulk string", line, err)
+    }
+    size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+    if err != nil {
+        t.Fatal(err)
+    }
+    data := make([]byte, size + 2)
+    if _, err = io.ReadFull(reader, data); err != nil {
+        t.Fatal(err)
+    }
+
+    fields := make(map[string]string)
+    for _, line := range strings.Split(string(data[:size]), "\r\n") {
+        if name, value, ok := strings.Cut(line, ":"); ok {
+            fields[name] = value
+        }
+    }
+    return fields
+}
+
+func TestRESPInfo(t *testing.T) {
+    conn, reader := start(t, newRESP)
+
+    // 105 keys in a cache of 100 evict the first 5
+    for i := 0; i < 105; i++ {
+        key := strconv.Itoa(i)
+        send(t, conn, fmt.Sprintf("*3\r\n$3\r\nSET\r\n$%d\r\n%v\r\n$1\r\nx\r\n", len(key), key))
+        expect(t, reader, "+OK")
+    }
+    send(t, conn, "*2\r\n$3\r\nGET\r\n$3\r\n104\r\n", "*2\r\n$3\r\nGET\r\n$3\r\n100\r\n", "*2\r\n$3\r\nGET\r\n$1\r\n0\r\n")
+    expect(t, reader, "$1", "x", "$1", "x", "$-1")
+
+    fields := info(t, conn, reader)
+    for name, expected := range map[string]string{
+        "keyspace_hits":    "2",
+        "keyspace_misses":  "1",
+        "evicted_keys":     "5",
+        "policy":           "lru",
+        "policy_capacity":  "100",
+        "policy_keys":      "100",
+        "policy_hits":      "2",
+        "policy_misses":    "105", // the first write of every key
+        "policy_writes":    "105",
+        "policy_evictions": "5",
+    } {
+        if fields[name] != expected {
+            t.Errorf("%v: got %q, expected %q", name, fields[name], expected)
+        }
+    }
+}
+
+func TestRESPFlushAll(t *testing.T) {
+    conn, reader := start(t, newRESP)
+
+    send(t, conn, "*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n", "*3\r\n$3\r\nSET\r\n$1\r\nb\r\n$1\r\n2\r\n")
+    expect(t, reader, "+OK", "+OK")
+    send(t, conn, "*1\r\n$8\r\nFLUSHALL\r\n")
+    expect(t, reader, "+OK")
+    send(t, conn, "*3\r\n$6\r\nEXISTS\r\n$1\r\na\r\n$1\r\nb\r\n", "*2\r\n$3\r\nGET\r\n$1\r\na\r\n")
+    expect(t, reader, ":0", "$-1")
+
+    if fields := info(t, conn, reader); fields["policy_keys"] != "0" {
+        t.Errorf("policy_keys: got %q after FLUSHALL, expected 0", fields["policy_keys"])
+    }
+}
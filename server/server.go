@@ -0,0 +1,128 @@
+package server
+
+import (
+    "bufio"
+    "errors"
+    "net"
+    "strings"
+    "sync"
+)
+
+const (
+    // maxLine bounds the length of a command line, which is read in the
+    // buffer of the connection
+    maxLine = 64 << 10
+    // DefaultMaxItemSize bounds the size of a value, as memcached does by
+    // default; values are only allocated once they are known to fit
+    DefaultMaxItemSize = 1 << 20
+)
+
+// errLineTooLong is returned for command lines longer than maxLine; the
+// connection is closed
+var errLineTooLong = errors.New("line too long")
+
+// listener accepts connections and hands them to a protocol handler, and
+// closes them all on shutdown
+type listener struct {
+    handle      func(conn net.Conn)
+
+    mu          sync.Mutex
+    listeners   []net.Listener
+    conns       map[net.Conn]struct{}
+    closed      bool
+    wg          sync.WaitGroup
+}
+
+func newListener(handle func(conn net.Conn)) listener {
+    return listener{
+        handle:     handle,
+        conns:      make(map[net.Conn]struct{}),
+    }
+}
+
+// ListenAndServe serves the cache on a TCP address, e.g. "127.0.0.1:6379"
+func (s *listener) ListenAndServe(addr string) (err error) {
+    l, err := net.Listen("tcp", addr)
+    if err != nil {
+        return err
+    }
+    return s.Serve(l)
+}
+
+// Serve accepts connections on l until Close is called
+func (s *listener) Serve(l net.Listener) (err error) {
+    s.mu.Lock()
+    if s.closed {
+        s.mu.Unlock()
+        l.Close()
+        return net.ErrClosed
+    }
+    s.listeners = append(s.listeners, l)
+    s.mu.Unlock()
+
+    for {
+        conn, err := l.Accept()
+        if err != nil {
+            s.mu.Lock()
+            closed := s.closed
+            s.mu.Unlock()
+            if closed {
+                return nil
+            }
+            return err
+        }
+
+        s.mu.Lock()
+        if s.closed {
+            s.mu.Unlock()
+            conn.Close()
+            return nil
+        }
+        s.conns[conn] = struct{}{}
+        s.wg.Add(1)
+        s.mu.Unlock()
+
+        go s.serve(conn)
+    }
+}
+
+// Close stops the listeners and closes the client connections, waiting for
+// the requests in progress
+func (s *listener) Close() (err error) {
+    s.mu.Lock()
+    s.closed = true
+    for _, l := range s.listeners {
+        l.Close()
+    }
+    for conn := range s.conns {
+        conn.Close()
+    }
+    s.mu.Unlock()
+
+    s.wg.Wait()
+    return nil
+}
+
+// readLine reads a line without its CRLF from a reader of maxLine bytes
+func readLine(r *bufio.Reader) (line string, err error) {
+    data, err := r.ReadSlice('\n')
+    if errors.Is(err, bufio.ErrBufferFull) {
+        return "", errLineTooLong
+    }
+    if err != nil {
+        return "", err
+    }
+    return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func (s *listener) serve(conn net.Conn) {
+    defer func() {
+        conn.Close()
+        s.mu.Lock()
+        delete(s.conns, conn)
+        s.mu.Unlock()
+        s.wg.Done()
+    }()
+
+    s.handle(conn)
+}
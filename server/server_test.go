@@ -0,0 +1,89 @@
+package server
+
+import (
+    "bufio"
+    "net"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/mohammadtauchid/golang-cache/v2/cache"
+)
+
+// testServer is a protocol server under test
+type testServer interface {
+    Serve(l net.Listener) error
+    Close() error
+}
+
+// start serves a cache of lru, which admits every key, on a loopback port
+// and connects a client to it
+func start(t *testing.T, create func(c *cache.Cache) testServer) (conn net.Conn, reader *bufio.Reader) {
+    t.Helper()
+
+    c, err := cache.New("lru", 100)
+    if err != nil {
+        t.Fatal(err)
+    }
+    srv := create(c)
+
+    l, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    go srv.Serve(l)
+    t.Cleanup(func() { srv.Close() })
+
+    conn, err = net.Dial("tcp", l.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    conn.SetDeadline(time.Now().Add(5 * time.Second))
+    t.Cleanup(func() { conn.Close() })
+
+    return conn, bufio.NewReader(conn)
+}
+
+// send writes raw requests to the server
+func send(t *testing.T, conn net.Conn, requests ...string) {
+    t.Helper()
+
+    if _, err := conn.Write([]byte(strings.Join(requests, ""))); err != nil {
+        t.Fatal(err)
+    }
+}
+
+// expect reads the given reply lines, without their CRLF
+func expect(t *testing.T, reader *bufio.Reader, lines ...string) {
+    t.Helper()
+
+    for _, expected := range lines {
+        line, err := reader.ReadString('\n')
+        if err != nil {
+            t.Fatalf("expected %q: %v", expected, err)
+        }
+        if line = strings.TrimRight(line, "\r\n"); line != expected {
+            t.Fatalf("got %q, expected %q", line, expected)
+        }
+    }
+}
+
+// expectClosed checks that the server closed the connection
+func expectClosed(t *testing.T, reader *bufio.Reader) {
+    t.Helper()
+
+    if line, err := reader.ReadString('\n'); err == nil {
+        t.Fatalf("got %q, expected the connection to be closed", line)
+    }
+}
+
+func TestReadLine(t *testing.T) {
+    reader := bufio.NewReaderSize(strings.NewReader("get foo\r\n" + strings.Repeat("a", maxLine)), maxLine)
+
+    if line, err := readLine(reader); err != nil || line != "get foo" {
+        t.Fatalf("got %q, %v, expected \"get foo\"", line, err)
+    }
+    if _, err := readLine(reader); err != errLineTooLong {
+        t.Fatalf("got %v, expected %v", err, errLineTooLong)
+    }
+}
@@ -0,0 +1,167 @@
+package simulator
+
+import (
+    "bufio"
+    "encoding/gob"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "hash/fnv"
+    "io"
+    "math"
+    "os"
+    "path/filepath"
+
+    "github.com/secnot/orderedmap"
+)
+
+const (
+    // SnapshotMagic starts every snapshot file
+    SnapshotMagic = "GCSNAP"
+    // SnapshotVersion is bumped whenever the state of a policy or the
+    // layout of a snapshot changes; snapshots of another version are
+    // rejected
+    SnapshotVersion = 2
+)
+
+type (
+    // Checkpointer is implemented by policies that can save their full
+    // state, so that a run can be resumed where it stopped
+    Checkpointer interface {
+        Checkpoint(enc *gob.Encoder) error
+        Restore(dec *gob.Decoder) error
+    }
+
+    // SnapshotHeader describes the run a snapshot was taken from
+    SnapshotHeader struct {
+        Magic       string
+        Version     int
+        Algorithm   string
+        Cache       int
+        Traces      int    // length of the trace
+        Fingerprint uint64 // hash of the trace, to detect another trace
+        Offset      int    // requests already simulated
+    }
+
+    // Entry is a page of a list, as saved in snapshots
+    Entry struct {
+        Key     Key
+        Value   Op
+    }
+)
+
+// SaveList returns the pages of a list from the first to the last
+func SaveList(list *orderedmap.OrderedMap) (entries []Entry) {
+    iter := list.Iter()
+    for key, value, ok := iter.Next(); ok; key, value, ok = iter.Next() {
+        op, _ := value.(Op)
+        entries = append(entries, Entry{Key: KeyOf(key), Value: op})
+    }
+    return entries
+}
+
+// LoadList rebuilds a list saved by SaveList
+func LoadList(entries []Entry) *orderedmap.OrderedMap {
+    list := orderedmap.NewOrderedMap()
+    for _, entry := range entries {
+        list.Set(entry.Key.MapKey(), entry.Value)
+    }
+    return list
+}
+
+// Fingerprint hashes every request of a trace, so that a snapshot is only
+// resumed on the trace it was taken from
+func Fingerprint(traces []Trace) uint64 {
+    var record [44]byte
+
+    h := fnv.New64a()
+    for _, trace := range traces {
+        binary.LittleEndian.PutUint64(record[0:], trace.Key.Hi)
+        binary.LittleEndian.PutUint64(record[8:], trace.Key.Lo)
+        binary.LittleEndian.PutUint64(record[16:], math.Float64bits(trace.Timestamp))
+        binary.LittleEndian.PutUint64(record[24:], uint64(trace.Size))
+        binary.LittleEndian.PutUint64(record[32:], uint64(trace.Tenant))
+        binary.LittleEndian.PutUint32(record[40:], uint32(trace.Op))
+        h.Write(record[:])
+    }
+    return h.Sum64()
+}
+
+// WriteSnapshot saves the state of the policy, followed by that of the
+// other states of the run such as observers, after header.Offset requests.
+// The file is replaced atomically, so a run killed while writing keeps its
+// previous snapshot.
+func WriteSnapshot(path string, header SnapshotHeader, states ...Checkpointer) (err error) {
+    file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path) + ".*")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(file.Name())
+
+    writer := bufio.NewWriter(file)
+    enc := gob.NewEncoder(writer)
+
+    header.Magic = SnapshotMagic
+    header.Version = SnapshotVersion
+    err = enc.Encode(header)
+    for _, state := range states {
+        if err != nil {
+            break
+        }
+        err = state.Checkpoint(enc)
+    }
+    if err == nil {
+        err = writer.Flush()
+    }
+    if closeErr := file.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        return err
+    }
+
+    return os.Rename(file.Name(), path)
+}
+
+// ReadSnapshot restores the states saved by WriteSnapshot, in the same
+// order, returning the header. The algorithm and cache size of the
+// snapshot must match the expected ones, and so must the trace.
+func ReadSnapshot(path string, expected SnapshotHeader, states ...Checkpointer) (header SnapshotHeader, err error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return header, err
+    }
+    defer file.Close()
+
+    dec := gob.NewDecoder(bufio.NewReader(file))
+    if err = dec.Decode(&header); err != nil {
+        return header, fmt.Errorf("%v: not a snapshot: %w", path, err)
+    }
+
+    switch {
+    case header.Magic != SnapshotMagic:
+        return header, fmt.Errorf("%v: not a snapshot", path)
+    case header.Version != SnapshotVersion:
+        return header, fmt.Errorf("%v: snapshot version %d, expected %d", path, header.Version, SnapshotVersion)
+    case header.Algorithm != expected.Algorithm || header.Cache != expected.Cache:
+        return header, fmt.Errorf(
+            "%v: snapshot of %v with %d pages, expected %v with %d pages",
+            path, header.Algorithm, header.Cache, expected.Algorithm, expected.Cache,
+        )
+    case header.Traces != expected.Traces || header.Offset > header.Traces:
+        return header, fmt.Errorf("%v: snapshot of a trace of %d requests, expected %d", path, header.Traces, expected.Traces)
+    case header.Fingerprint != expected.Fingerprint:
+        return header, fmt.Errorf("%v: snapshot of another trace", path)
+    }
+
+    for _, state := range states {
+        if err = state.Restore(dec); err != nil {
+            if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+                err = errors.New("truncated snapshot")
+            }
+            return header, fmt.Errorf("%v: %w", path, err)
+        }
+    }
+
+    return header, nil
+}
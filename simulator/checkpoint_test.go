@@ -0,0 +1,147 @@
+package simulator_test
+
+import (
+    "bytes"
+    "math/rand"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/mohammadtauchid/golang-cache/v2/arc"
+    "github.com/mohammadtauchid/golang-cache/v2/larc"
+    "github.com/mohammadtauchid/golang-cache/v2/lru"
+    "github.com/mohammadtauchid/golang-cache/v2/marc"
+    "github.com/mohammadtauchid/golang-cache/v2/simulator"
+)
+
+// checkpointed is a simulator that can be saved to snapshots
+type checkpointed interface {
+    simulator.Simulator
+    simulator.Checkpointer
+}
+
+var policies = map[string]func(size int) checkpointed{
+    "lru":  func(size int) checkpointed { return lru.NewLRU(size) },
+    "arc":  func(size int) checkpointed { return arc.NewARC(size) },
+    "larc": func(size int) checkpointed { return larc.NewLARC(size) },
+    "marc": func(size int) checkpointed { return marc.NewMARC(size) },
+}
+
+// requests mixes a skewed working set with scans, writes and deletes, over
+// two tenants
+func requests(n int, seed int64) (traces []simulator.Trace) {
+    random := rand.New(rand.NewSource(seed))
+    for i := 0; i < n; i++ {
+        trace := simulator.Trace{Op: simulator.OpRead, Tenant: random.Intn(2)}
+        switch {
+        case i % 5000 < 500:
+            trace.Key = simulator.IntKey(uint64(100000 + i))
+        default:
+            trace.Key = simulator.IntKey(uint64(random.ExpFloat64() * 300))
+        }
+        switch random.Intn(20) {
+        case 0:
+            trace.Op = simulator.OpWrite
+        case 1:
+            trace.Op = simulator.OpDelete
+        }
+        traces = append(traces, trace)
+    }
+    return traces
+}
+
+// render returns the result of a run, with its counters per tenant
+func render(t *testing.T, sim simulator.Simulator, tenants *simulator.TenantStats) string {
+    var b bytes.Buffer
+
+    if err := sim.PrintToFile(&b, 0); err != nil {
+        t.Fatal(err)
+    }
+    tenants.PrintToFile(&b)
+    return b.String()
+}
+
+// TestResume checks that a run stopped at a snapshot and resumed from it
+// ends like an uninterrupted run
+func TestResume(t *testing.T) {
+    traces := requests(50000, 1)
+    header := simulator.SnapshotHeader{
+        Cache:          500,
+        Traces:         len(traces),
+        Fingerprint:    simulator.Fingerprint(traces),
+    }
+
+    for algorithm, create := range policies {
+        header.Algorithm = algorithm
+        path := filepath.Join(t.TempDir(), algorithm + ".snapshot")
+
+        full, fullTenants := create(500), simulator.NewTenantStats()
+        if err := simulator.Run(full, traces, fullTenants); err != nil {
+            t.Fatal(err)
+        }
+
+        for _, offset := range []int{1, 20000, 37123} {
+            stopped, stoppedTenants := create(500), simulator.NewTenantStats()
+            if err := simulator.Run(stopped, traces[:offset], stoppedTenants); err != nil {
+                t.Fatal(err)
+            }
+            header.Offset = offset
+            if err := simulator.WriteSnapshot(path, header, stopped, stoppedTenants); err != nil {
+                t.Fatalf("%v: %v", algorithm, err)
+            }
+
+            resumed, resumedTenants := create(500), simulator.NewTenantStats()
+            restored, err := simulator.ReadSnapshot(path, header, resumed, resumedTenants)
+            if err != nil {
+                t.Fatalf("%v: %v", algorithm, err)
+            }
+            if restored.Offset != offset {
+                t.Fatalf("%v: resumed at %d, expected %d", algorithm, restored.Offset, offset)
+            }
+            if err = simulator.Run(resumed, traces[restored.Offset:], resumedTenants); err != nil {
+                t.Fatal(err)
+            }
+
+            if resumed.Stats() != full.Stats() {
+                t.Errorf("%v resumed at %d: %+v, expected %+v", algorithm, offset, resumed.Stats(), full.Stats())
+            }
+            if got, expected := render(t, resumed, resumedTenants), render(t, full, fullTenants); got != expected {
+                t.Errorf("%v resumed at %d:\n%v\nexpected:\n%v", algorithm, offset, got, expected)
+            }
+        }
+    }
+}
+
+// TestResumeAnotherTrace checks that a snapshot is refused for another
+// trace of the same length, or another policy
+func TestResumeAnotherTrace(t *testing.T) {
+    traces, other := requests(1000, 1), requests(1000, 2)
+    path := filepath.Join(t.TempDir(), "lru.snapshot")
+    header := simulator.SnapshotHeader{
+        Algorithm:      "lru",
+        Cache:          100,
+        Traces:         len(traces),
+        Fingerprint:    simulator.Fingerprint(traces),
+        Offset:         500,
+    }
+
+    sim := lru.NewLRU(100)
+    if err := simulator.Run(sim, traces[:500]); err != nil {
+        t.Fatal(err)
+    }
+    if err := simulator.WriteSnapshot(path, header, sim); err != nil {
+        t.Fatal(err)
+    }
+
+    expected := header
+    expected.Fingerprint = simulator.Fingerprint(other)
+    if _, err := simulator.ReadSnapshot(path, expected, lru.NewLRU(100)); err == nil || !strings.Contains(err.Error(), "another trace") {
+        t.Errorf("got %v, expected a snapshot of another trace", err)
+    }
+
+    expected = header
+    expected.Algorithm = "arc"
+    if _, err := simulator.ReadSnapshot(path, expected, arc.NewARC(100)); err == nil {
+        t.Errorf("snapshot of lru restored into arc")
+    }
+}
@@ -0,0 +1,68 @@
+package simulator
+
+import (
+    "fmt"
+    "io"
+)
+
+// pBuckets is the number of buckets of the histogram of p
+const pBuckets = 10
+
+type (
+    // Details is implemented by simulators reporting policy specific
+    // results; they are stored with the statistics in the results file
+    Details interface {
+        Details() interface{}
+    }
+
+    // GhostStats describes how an ARC-style policy used its lists: hits in
+    // the ghost lists B1 and B2, promotions from T1 to T2, evictions from
+    // T1 and T2, how the target size p of T1 evolved and the final sizes
+    GhostStats struct {
+        B1Hits          int     `json:"b1_hits"`
+        B2Hits          int     `json:"b2_hits"`
+        Promotions      int     `json:"t1_to_t2_promotions"`
+        T1Evictions     int     `json:"t1_evictions"`
+        T2Evictions     int     `json:"t2_evictions"`
+
+        // PHistogram counts the requests served while p was in each tenth
+        // of the cache size
+        PHistogram      []int   `json:"p_histogram"`
+
+        P               int     `json:"p"`
+        T1              int     `json:"t1_size"`
+        T2              int     `json:"t2_size"`
+        B1              int     `json:"b1_size"`
+        B2              int     `json:"b2_size"`
+    }
+)
+
+func NewGhostStats() *GhostStats {
+    return &GhostStats{
+        PHistogram: make([]int, pBuckets),
+    }
+}
+
+// ObserveP counts a request served while p had the given value
+func (g *GhostStats) ObserveP(p int, capacity int) {
+    bucket := 0
+    if capacity > 0 {
+        bucket = p * pBuckets / capacity
+    }
+    if bucket >= pBuckets {
+        bucket = pBuckets - 1
+    }
+    g.PHistogram[bucket]++
+}
+
+func (g *GhostStats) PrintToFile(file io.Writer) (err error) {
+    fmt.Fprintf(file, "b1 ghost hit: %d\n", g.B1Hits)
+    fmt.Fprintf(file, "b2 ghost hit: %d\n", g.B2Hits)
+    fmt.Fprintf(file, "t1 to t2 promotion: %d\n", g.Promotions)
+    fmt.Fprintf(file, "t1 eviction: %d\n", g.T1Evictions)
+    fmt.Fprintf(file, "t2 eviction: %d\n", g.T2Evictions)
+    fmt.Fprintf(file, "final p: %d\n", g.P)
+    fmt.Fprintf(file, "final list sizes: t1 %d, t2 %d, b1 %d, b2 %d\n", g.T1, g.T2, g.B1, g.B2)
+
+    return nil
+}
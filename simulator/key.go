@@ -0,0 +1,97 @@
+package simulator
+
+import (
+    "fmt"
+    "hash/fnv"
+    "strconv"
+)
+
+// Key identifies a cached object. Block traces use the address as the low
+// 64 bits; string and byte keys, such as URLs or 128-bit object ids, are
+// stored as their 128-bit FNV-1a hash so every key has the same compact,
+// comparable representation.
+type Key struct {
+    Hi  uint64
+    Lo  uint64
+}
+
+// IntKey returns the key of a block address
+func IntKey(addr uint64) Key {
+    return Key{Lo: addr}
+}
+
+// VolumeBits is the width of a block address within a volume
+const VolumeBits = 48
+
+// VolumeKey returns the key of a block address on a volume of a trace of
+// several volumes. The volume is placed above the address in the low 64
+// bits, so the keys stay block addresses to the maps and prefetchers.
+func VolumeKey(volume, addr uint64) Key {
+    return Key{Lo: volume << VolumeBits | addr}
+}
+
+// StringKey returns the hashed key of an arbitrary string
+func StringKey(s string) Key {
+    hash := fnv.New128a()
+    hash.Write([]byte(s))
+    return hashKey(hash.Sum(nil))
+}
+
+// BytesKey returns the hashed key of an arbitrary byte string
+func BytesKey(b []byte) Key {
+    hash := fnv.New128a()
+    hash.Write(b)
+    return hashKey(hash.Sum(nil))
+}
+
+func hashKey(sum []byte) (key Key) {
+    for i := 0; i < 8; i++ {
+        key.Hi = key.Hi << 8 | uint64(sum[i])
+        key.Lo = key.Lo << 8 | uint64(sum[8 + i])
+    }
+    return key
+}
+
+// Less orders keys, as the sorted filters of larc and marc need
+func (k Key) Less(other Key) bool {
+    return k.Hi < other.Hi || (k.Hi == other.Hi && k.Lo < other.Lo)
+}
+
+// Next returns the key of the following address
+func (k Key) Next() Key {
+    if k.Lo == ^uint64(0) {
+        return Key{Hi: k.Hi + 1, Lo: 0}
+    }
+    return Key{Hi: k.Hi, Lo: k.Lo + 1}
+}
+
+// String prints block addresses in decimal and wider keys as 0x prefixed
+// 128-bit hexadecimal, both of which the csv trace reader accepts
+func (k Key) String() string {
+    if k.Hi == 0 {
+        return strconv.FormatUint(k.Lo, 10)
+    }
+    return fmt.Sprintf("0x%016x%016x", k.Hi, k.Lo)
+}
+
+// MapKey returns the representation of k used as an interface{} map key.
+// Block addresses are boxed as a plain uint64, which is much cheaper to
+// allocate and hash than the full struct.
+func (k Key) MapKey() interface{} {
+    if k.Hi == 0 {
+        return k.Lo
+    }
+    return k
+}
+
+// KeyOf converts a map key returned by MapKey back into a Key
+func KeyOf(mapKey interface{}) Key {
+    switch key := mapKey.(type) {
+    case uint64:
+        return IntKey(key)
+    case Key:
+        return key
+    default:
+        panic(fmt.Sprintf("not a cache key: %v", mapKey))
+    }
+}
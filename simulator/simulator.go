@@ -1,16 +1,116 @@
 package simulator
 
 import (
-    "os"
+    "fmt"
+    "io"
+    "strings"
     "time"
 )
 
 type Simulator interface {
     Get(Trace) error
-    PrintToFile(file *os.File, start time.Time) error
+    Stats() Stats
+    PrintToFile(file io.Writer, elapsed time.Duration) error
+}
+
+// Stats is a point-in-time view of the counters every policy keeps
+type Stats struct {
+    Hit         int `json:"hit"`
+    Miss        int `json:"miss"`
+    Write       int `json:"write"`
+    Eviction    int `json:"eviction"`
+    Delete      int `json:"delete"`     // cached pages removed by delete requests
+    Occupancy   int `json:"occupancy"`  // number of pages currently cached
+    Capacity    int `json:"capacity"`
+}
+
+// Telemetry is implemented by simulators that record how their adaptation
+// parameters evolve during a run.
+type Telemetry interface {
+    PrintTelemetryToFile(file io.Writer) error
+}
+
+// Policy is implemented by simulators that can be composed with others, e.g.
+// as a tier of a hierarchy: cached pages can be looked up and removed
+// without touching the statistics, and evictions are reported to a callback.
+type Policy interface {
+    Simulator
+    Contains(key Key) bool
+    Remove(key Key) bool
+    OnEvict(fn func(key Key))
+}
+
+// Resizer is implemented by policies whose capacity can change during a
+// run, e.g. when a shared cache is repartitioned
+type Resizer interface {
+    Resize(capacity int)
+}
+
+// Lister is implemented by policies that can list their cached pages, in
+// the order in which they should be inserted to rebuild the cache
+type Lister interface {
+    Keys() []Key
+}
+
+// Op is the operation of a trace record
+type Op uint8
+
+const (
+    OpRead Op = iota
+    OpWrite
+    OpDelete // removes the address from the cache
+)
+
+// ops maps the operation names found in traces onto Op
+var ops = map[string]Op{
+    "r":        OpRead,
+    "read":     OpRead,
+    "get":      OpRead,
+    "gets":     OpRead,
+    "w":        OpWrite,
+    "write":    OpWrite,
+    "set":      OpWrite,
+    "d":        OpDelete,
+    "delete":   OpDelete,
+    "del":      OpDelete,
+}
+
+// ParseOp reads an operation name, case-insensitively
+func ParseOp(name string) (op Op, err error) {
+    op, ok := ops[strings.ToLower(strings.TrimSpace(name))]
+    if !ok {
+        return op, fmt.Errorf("unknown operation %q", name)
+    }
+    return op, nil
+}
+
+func (op Op) String() string {
+    switch op {
+    case OpRead:
+        return "R"
+    case OpWrite:
+        return "W"
+    case OpDelete:
+        return "D"
+    default:
+        return fmt.Sprintf("Op(%d)", uint8(op))
+    }
+}
+
+// Valid reports whether op is one of the known operations
+func (op Op) Valid() bool {
+    return op <= OpDelete
 }
 
 type Trace struct {
-    Addr    int
-    Op      string
+    Key         Key
+    Op          Op
+    Timestamp   float64 // seconds; zero when the trace carries no time
+    Size        int     // bytes; zero when the trace carries no size
+    Tenant      int     // volume, host or client issuing the request; zero when unknown
+}
+
+// IsWrite reports whether the trace record is a write request
+func (trace Trace) IsWrite() bool {
+    return trace.Op == OpWrite
 }
\ No newline at end of file
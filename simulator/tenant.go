@@ -0,0 +1,132 @@
+package simulator
+
+import (
+    "encoding/gob"
+    "fmt"
+    "io"
+    "sort"
+)
+
+// TenantStats attributes the counters of a simulator to the tenants issuing
+// the requests. As an Observer it works with every policy: the change of
+// the counters caused by a request is credited to the tenant of the request.
+type TenantStats struct {
+    tenants     map[int]*Stats
+}
+
+// Tenants returns the distinct tenants of a trace in increasing order
+func Tenants(traces []Trace) (tenants []int) {
+    seen := make(map[int]struct{})
+    for _, trace := range traces {
+        if _, ok := seen[trace.Tenant]; !ok {
+            seen[trace.Tenant] = struct{}{}
+            tenants = append(tenants, trace.Tenant)
+        }
+    }
+    sort.Ints(tenants)
+
+    return tenants
+}
+
+func NewTenantStats() *TenantStats {
+    return &TenantStats{
+        tenants:    make(map[int]*Stats),
+    }
+}
+
+func (s *TenantStats) Observe(trace Trace, before Stats, after Stats) {
+    stats, ok := s.tenants[trace.Tenant]
+    if !ok {
+        stats = &Stats{}
+        s.tenants[trace.Tenant] = stats
+    }
+
+    stats.Hit += after.Hit - before.Hit
+    stats.Miss += after.Miss - before.Miss
+    stats.Write += after.Write - before.Write
+    stats.Eviction += after.Eviction - before.Eviction
+    stats.Delete += after.Delete - before.Delete
+}
+
+// Tenants returns the tenants seen so far in increasing order
+func (s *TenantStats) Tenants() (tenants []int) {
+    for tenant := range s.tenants {
+        tenants = append(tenants, tenant)
+    }
+    sort.Ints(tenants)
+
+    return tenants
+}
+
+// Get returns the counters attributed to a tenant; evictions are those
+// caused by its requests, whichever tenant the evicted pages belonged to
+func (s *TenantStats) Get(tenant int) Stats {
+    if stats, ok := s.tenants[tenant]; ok {
+        return *stats
+    }
+    return Stats{}
+}
+
+// HitRatio returns the hit ratio of a tenant, as a fraction
+func (s *TenantStats) HitRatio(tenant int) float64 {
+    stats := s.Get(tenant)
+    if stats.Hit + stats.Miss == 0 {
+        return 0
+    }
+    return float64(stats.Hit) / float64(stats.Hit + stats.Miss)
+}
+
+// Fairness returns Jain's fairness index of the hit ratios of the tenants:
+// 1 when every tenant has the same hit ratio, 1/n at worst
+func (s *TenantStats) Fairness() float64 {
+    var sum, squares float64
+
+    for tenant := range s.tenants {
+        ratio := s.HitRatio(tenant)
+        sum += ratio
+        squares += ratio * ratio
+    }
+
+    if squares == 0 {
+        return 1
+    }
+    return sum * sum / (float64(len(s.tenants)) * squares)
+}
+
+// Checkpoint saves the counters of every tenant, so that a resumed run
+// reports the tenants of the whole trace
+func (s *TenantStats) Checkpoint(enc *gob.Encoder) (err error) {
+    tenants := make(map[int]Stats, len(s.tenants))
+    for tenant, stats := range s.tenants {
+        tenants[tenant] = *stats
+    }
+    return enc.Encode(tenants)
+}
+
+func (s *TenantStats) Restore(dec *gob.Decoder) (err error) {
+    var tenants map[int]Stats
+
+    if err = dec.Decode(&tenants); err != nil {
+        return err
+    }
+
+    s.tenants = make(map[int]*Stats, len(tenants))
+    for tenant, stats := range tenants {
+        stats := stats
+        s.tenants[tenant] = &stats
+    }
+    return nil
+}
+
+func (s *TenantStats) PrintToFile(file io.Writer) (err error) {
+    for _, tenant := range s.Tenants() {
+        stats := s.Get(tenant)
+        fmt.Fprintf(
+            file, "tenant %d: hit %d, miss %d, hit ratio %.4f%%, write %d\n",
+            tenant, stats.Hit, stats.Miss, s.HitRatio(tenant) * 100, stats.Write,
+        )
+    }
+    fmt.Fprintf(file, "fairness (jain's index of hit ratios): %.4f\n", s.Fairness())
+
+    return nil
+}
@@ -0,0 +1,135 @@
+package simulator
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+)
+
+// WarmUp excludes the cold-start phase of a run from its statistics. The
+// policy keeps serving requests during warm-up, only the counters reported
+// afterwards start from the state reached at the end of it.
+type WarmUp struct {
+    requests    int
+    untilFull   bool
+
+    index       int
+    done        bool
+    start       Stats
+}
+
+// NewWarmUp creates a warm-up lasting the given number of requests, or until
+// the cache is full for the first time when untilFull is set
+func NewWarmUp(requests int, untilFull bool) *WarmUp {
+    return &WarmUp{
+        requests:   requests,
+        untilFull:  untilFull,
+        index:      0,
+        done:       false,
+    }
+}
+
+// ParseWarmUp reads a warm-up option: a number of requests ("10000"), a
+// fraction of the trace length ("10%", "0.1") or "full"
+func ParseWarmUp(value string, length int) (warmup *WarmUp, err error) {
+    var fraction float64
+
+    value = strings.TrimSpace(strings.ToLower(value))
+
+    if value == "full" {
+        return NewWarmUp(0, true), nil
+    }
+
+    if strings.HasSuffix(value, "%") {
+        fraction, err = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+        fraction /= 100
+    } else if requests, atoiErr := strconv.Atoi(value); atoiErr == nil {
+        if requests < 0 {
+            return nil, fmt.Errorf("invalid warm-up %q: must not be negative", value)
+        }
+        return NewWarmUp(requests, false), nil
+    } else {
+        fraction, err = strconv.ParseFloat(value, 64)
+    }
+
+    if err != nil {
+        return nil, errors.New("warm-up must be a request count, a fraction of the trace or \"full\"")
+    }
+
+    if fraction < 0 || fraction > 1 {
+        return nil, fmt.Errorf("invalid warm-up %q: fraction must be between 0 and 1", value)
+    }
+
+    return NewWarmUp(int(fraction * float64(length)), false), nil
+}
+
+func (w *WarmUp) Observe(trace Trace, before Stats, after Stats) {
+    if w.done {
+        return
+    }
+
+    if !w.untilFull && w.requests == 0 {
+        w.done = true
+        w.start = before
+        return
+    }
+
+    w.index++
+
+    if (w.untilFull && after.Occupancy >= after.Capacity) ||
+        (!w.untilFull && w.index >= w.requests) {
+        w.done = true
+        w.start = after
+    }
+}
+
+// Done reports whether the warm-up phase has ended
+func (w *WarmUp) Done() bool {
+    return w.done
+}
+
+// Requests returns the number of requests served during warm-up
+func (w *WarmUp) Requests() int {
+    return w.index
+}
+
+// Stats returns the counters accumulated after warm-up, given the totals
+func (w *WarmUp) Stats(total Stats) Stats {
+    if !w.done {
+        return Stats{Occupancy: total.Occupancy, Capacity: total.Capacity}
+    }
+
+    return Stats{
+        Hit:        total.Hit - w.start.Hit,
+        Miss:       total.Miss - w.start.Miss,
+        Write:      total.Write - w.start.Write,
+        Eviction:   total.Eviction - w.start.Eviction,
+        Delete:     total.Delete - w.start.Delete,
+        Occupancy:  total.Occupancy,
+        Capacity:   total.Capacity,
+    }
+}
+
+func (w *WarmUp) PrintToFile(file io.Writer, total Stats) (err error) {
+    warm := w.Stats(total)
+
+    fmt.Fprintf(file, "warm-up requests: %d\n", w.index)
+    if !w.done {
+        io.WriteString(file, "warm-up did not complete\n")
+        return nil
+    }
+
+    ratio := 0.0
+    if warm.Hit + warm.Miss > 0 {
+        ratio = float64(warm.Hit) / float64(warm.Hit + warm.Miss) * 100
+    }
+    fmt.Fprintf(file, "warm cache hit: %d\n", warm.Hit)
+    fmt.Fprintf(file, "warm cache miss: %d\n", warm.Miss)
+    fmt.Fprintf(file, "warm cache hit ratio: %.4f%%\n", ratio)
+    fmt.Fprintf(file, "warm write count: %d\n", warm.Write)
+    fmt.Fprintf(file, "warm delete count: %d\n", warm.Delete)
+
+    return nil
+}
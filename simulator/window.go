@@ -0,0 +1,152 @@
+package simulator
+
+import (
+    "fmt"
+    "math"
+    "io"
+)
+
+type (
+    // Observer is notified of every request served by a Simulator together
+    // with the counters before and after the request
+    Observer interface {
+        Observe(trace Trace, before Stats, after Stats)
+    }
+
+    // Window holds the counters accumulated over one window of requests
+    Window struct {
+        Start       int // index of the first request in the window
+        End         int // index past the last request in the window
+        StartTime   float64
+        EndTime     float64
+        Hit         int
+        Miss        int
+        Write       int
+        Eviction    int
+        Occupancy   int // occupancy at the end of the window
+    }
+
+    // WindowRecorder splits a run into windows of a fixed number of requests
+    // or a fixed span of trace time, whichever is configured
+    WindowRecorder struct {
+        requests    int
+        duration    float64
+
+        index       int
+        origin      float64
+        current     Window
+        first       Stats
+        windows     []Window
+    }
+)
+
+// Run feeds every trace to sim, notifying the observers around each request
+func Run(sim Simulator, traces []Trace, observers ...Observer) (err error) {
+    var before Stats
+
+    for _, trace := range traces {
+        if len(observers) > 0 {
+            before = sim.Stats()
+        }
+
+        if err = sim.Get(trace); err != nil {
+            return err
+        }
+
+        if len(observers) > 0 {
+            after := sim.Stats()
+            for _, observer := range observers {
+                observer.Observe(trace, before, after)
+            }
+        }
+    }
+
+    return nil
+}
+
+// NewWindowRecorder creates a recorder that closes a window every requests
+// requests, or every duration seconds of trace time when duration is set
+func NewWindowRecorder(requests int, duration float64) *WindowRecorder {
+    return &WindowRecorder{
+        requests:   requests,
+        duration:   duration,
+        index:      0,
+        windows:    make([]Window, 0),
+    }
+}
+
+func (w *WindowRecorder) Observe(trace Trace, before Stats, after Stats) {
+    if w.index == 0 {
+        w.origin = trace.Timestamp
+        w.open(0, trace.Timestamp, before)
+    }
+
+    if w.duration > 0 {
+        // a request past the time span belongs to a later window
+        if trace.Timestamp >= w.current.StartTime + w.duration {
+            w.close(before)
+            span := math.Floor((trace.Timestamp - w.origin) / w.duration)
+            w.open(w.index, w.origin + span * w.duration, before)
+        }
+    } else if w.current.End == w.current.Start {
+        w.current.StartTime = trace.Timestamp
+    }
+
+    w.index++
+    w.current.End = w.index
+    w.current.EndTime = trace.Timestamp
+
+    if w.duration <= 0 && w.requests > 0 && w.current.End - w.current.Start == w.requests {
+        w.close(after)
+        w.open(w.index, trace.Timestamp, after)
+    }
+}
+
+// Flush closes the last, possibly partial, window
+func (w *WindowRecorder) Flush(sim Simulator) {
+    if w.current.End > w.current.Start {
+        stats := sim.Stats()
+        w.close(stats)
+        w.open(w.index, w.current.EndTime, stats)
+    }
+}
+
+func (w *WindowRecorder) Windows() []Window {
+    return w.windows
+}
+
+func (w *WindowRecorder) open(start int, startTime float64, stats Stats) {
+    w.current = Window{
+        Start:      start,
+        End:        start,
+        StartTime:  startTime,
+        EndTime:    startTime,
+    }
+    w.first = stats
+}
+
+func (w *WindowRecorder) close(stats Stats) {
+    w.current.Hit = stats.Hit - w.first.Hit
+    w.current.Miss = stats.Miss - w.first.Miss
+    w.current.Write = stats.Write - w.first.Write
+    w.current.Eviction = stats.Eviction - w.first.Eviction
+    w.current.Occupancy = stats.Occupancy
+    w.windows = append(w.windows, w.current)
+}
+
+func (w *WindowRecorder) PrintToFile(file io.Writer) (err error) {
+    io.WriteString(file, "window,start,end,start time,end time,hit,miss,hit ratio,write,eviction,occupancy\n")
+    for i, window := range w.windows {
+        ratio := 0.0
+        if window.Hit + window.Miss > 0 {
+            ratio = float64(window.Hit) / float64(window.Hit + window.Miss) * 100
+        }
+        fmt.Fprintf(
+            file, "%d,%d,%d,%.6f,%.6f,%d,%d,%.4f,%d,%d,%d\n",
+            i, window.Start, window.End, window.StartTime, window.EndTime,
+            window.Hit, window.Miss, ratio, window.Write, window.Eviction, window.Occupancy,
+        )
+    }
+
+    return nil
+}